@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"golang.org/x/time/rate"
+)
+
+var errInvalidBandwidth = errors.New("invalid bandwidth limit, expected a value like 20MB/s")
+
+// sizeUnits maps the supported units to their size in bytes.
+var sizeUnits = map[string]float64{
+	"":    1,
+	"b":   1,
+	"k":   1 << 10,
+	"kb":  1000,
+	"kib": 1 << 10,
+	"m":   1 << 20,
+	"mb":  1000 * 1000,
+	"mib": 1 << 20,
+	"g":   1 << 30,
+	"gb":  1000 * 1000 * 1000,
+	"gib": 1 << 30,
+}
+
+// parseSize converts a size like "20MB" or "512KiB" to bytes, returning false
+// for malformed or non positive sizes.
+func parseSize(value string) (int64, bool) {
+	i := strings.IndexFunc(value, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(value)
+	}
+
+	number, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil || number <= 0 {
+		return 0, false
+	}
+	unit, ok := sizeUnits[strings.ToLower(strings.TrimSpace(value[i:]))]
+	if !ok {
+		return 0, false
+	}
+
+	size := int64(number * unit)
+	return size, size >= 1
+}
+
+// parseBandwidth converts a limit like "20MB/s" or "512KiB" to bytes per second.
+// An empty value means no limit.
+func parseBandwidth(value string) (int64, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	value = strings.TrimSuffix(value, "/s")
+	if value == "" {
+		return 0, nil
+	}
+
+	limit, ok := parseSize(value)
+	if !ok {
+		return 0, errInvalidBandwidth
+	}
+	return limit, nil
+}
+
+// newLimiter returns a limiter for the given bytes per second, nil for no limit.
+func newLimiter(limit int64) *rate.Limiter {
+	if limit <= 0 {
+		return nil
+	}
+	burst := int(min(limit, 64<<10))
+	return rate.NewLimiter(rate.Limit(limit), burst)
+}
+
+// throttledReader waits for every limiter before handing out data.
+type throttledReader struct {
+	reader   io.Reader
+	limiters []*rate.Limiter
+	burst    int
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	if len(p) > r.burst {
+		p = p[:r.burst]
+	}
+	n, err := r.reader.Read(p)
+	for _, l := range r.limiters {
+		if werr := l.WaitN(context.Background(), n); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return n, err
+}
+
+// throttle limits the reader to the per host bandwidth and the global
+// bandwidth shared by all hosts.
+func (p *Plugin) throttle(reader io.Reader) io.Reader {
+	var limiters []*rate.Limiter
+	if l := newLimiter(p.bandwidth); l != nil {
+		limiters = append(limiters, l)
+	}
+	if p.totalBandwidth != nil {
+		limiters = append(limiters, p.totalBandwidth)
+	}
+	if len(limiters) == 0 {
+		return reader
+	}
+
+	burst := limiters[0].Burst()
+	for _, l := range limiters[1:] {
+		burst = min(burst, l.Burst())
+	}
+	return &throttledReader{reader: reader, limiters: limiters, burst: burst}
+}
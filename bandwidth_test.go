@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBandwidth(t *testing.T) {
+	tests := []struct {
+		value string
+		want  int64
+	}{
+		{"", 0},
+		{"1024", 1024},
+		{"20MB/s", 20 * 1000 * 1000},
+		{"20 MiB/s", 20 << 20},
+		{"512k", 512 << 10},
+		{"1.5GB", 1500 * 1000 * 1000},
+		{"100KB/s", 100 * 1000},
+	}
+	for _, tt := range tests {
+		limit, err := parseBandwidth(tt.value)
+		assert.NoError(t, err, tt.value)
+		assert.Equal(t, tt.want, limit, tt.value)
+	}
+
+	for _, value := range []string{"fast", "20XB/s", "-1MB", "0", "MB/s"} {
+		_, err := parseBandwidth(value)
+		assert.Equal(t, errInvalidBandwidth, err, value)
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	p := Plugin{}
+	reader := bytes.NewReader([]byte("hello"))
+	assert.Equal(t, reader, p.throttle(reader))
+
+	// 64 KiB burst plus 64 KiB at 128 KiB/s takes about half a second
+	p.bandwidth = 128 << 10
+	data := make([]byte, 128<<10)
+	start := time.Now()
+	n, err := io.Copy(io.Discard, p.throttle(bytes.NewReader(data)))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), n)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	// the global limit is shared by all readers
+	p.bandwidth = 0
+	p.totalBandwidth = newLimiter(64 << 10)
+	start = time.Now()
+	_, err = io.Copy(io.Discard, p.throttle(bytes.NewReader(data[:64<<10])))
+	assert.NoError(t, err)
+	_, err = io.Copy(io.Discard, p.throttle(bytes.NewReader(data[:32<<10])))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
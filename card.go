@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+)
+
+type (
+	// adaptiveCard is the card rendered by Drone and Woodpecker for a step.
+	adaptiveCard struct {
+		Type    string        `json:"type"`
+		Schema  string        `json:"$schema"`
+		Version string        `json:"version"`
+		Body    []cardElement `json:"body"`
+	}
+
+	cardElement struct {
+		Type   string        `json:"type"`
+		Text   string        `json:"text,omitempty"`
+		Size   string        `json:"size,omitempty"`
+		Weight string        `json:"weight,omitempty"`
+		Color  string        `json:"color,omitempty"`
+		Wrap   bool          `json:"wrap,omitempty"`
+		Facts  []cardFact    `json:"facts,omitempty"`
+		Items  []cardElement `json:"items,omitempty"`
+	}
+
+	cardFact struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+	}
+)
+
+func cardColor(status string) string {
+	switch status {
+	case statusSuccess:
+		return "good"
+	case statusFailure:
+		return "attention"
+	}
+	return "warning"
+}
+
+// card renders the report with a container for every host listing its targets.
+func (r *report) card(targets []string) adaptiveCard {
+	card := adaptiveCard{
+		Type:    "AdaptiveCard",
+		Schema:  "http://adaptivecards.io/schemas/adaptive-card.json",
+		Version: "1.5",
+		Body: []cardElement{
+			{Type: "TextBlock", Text: "drone-scp " + r.Status, Size: "Medium", Weight: "Bolder", Color: cardColor(r.Status)},
+			{Type: "FactSet", Facts: []cardFact{
+				{Title: "Hosts", Value: fmt.Sprint(len(r.Hosts))},
+				{Title: "Targets", Value: fmt.Sprint(len(targets))},
+				{Title: "Files", Value: fmt.Sprint(r.Files)},
+				{Title: "Archive", Value: formatBytes(r.Archive.Size)},
+				{Title: "Sent", Value: formatBytes(r.Bytes)},
+				{Title: "Duration", Value: fmt.Sprintf("%.1fs", r.Duration)},
+			}},
+		},
+	}
+
+	cases := r.junit(targets).Suites[0].Cases
+	for _, h := range r.Hosts {
+		host := cardElement{
+			Type: "Container",
+			Items: []cardElement{
+				{Type: "TextBlock", Text: net.JoinHostPort(h.Host, h.Port) + ": " + h.Status, Weight: "Bolder", Color: cardColor(h.Status)},
+			},
+		}
+		if h.Error != "" {
+			host.Items = append(host.Items, cardElement{Type: "TextBlock", Text: h.Error, Wrap: true, Color: "attention"})
+		}
+
+		var facts []cardFact
+		n := len(h.expected(targets))
+		for _, c := range cases[:n] {
+			status := statusSuccess
+			switch {
+			case c.Failure != nil:
+				status = statusFailure
+			case c.Skipped != nil:
+				status = statusSkipped
+			}
+			facts = append(facts, cardFact{Title: c.Name, Value: fmt.Sprintf("%s (%.1fs)", status, c.Time)})
+		}
+		cases = cases[n:]
+		host.Items = append(host.Items, cardElement{Type: "FactSet", Facts: facts})
+		card.Body = append(card.Body, host)
+	}
+	return card
+}
+
+func (r *report) writeCard(file string, targets []string) error {
+	data, err := json.Marshal(r.card(targets))
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0o644)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCard(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Port: 22,
+		},
+	}
+	rep := p.newReport([]string{"a.example.com", "b.example.com"})
+	rep.Status = statusFailure
+	rep.Files = 3
+	rep.Hosts[0].target("/var/www").finish(nil)
+	rep.Hosts[0].target("/var/backup").finish(nil)
+	rep.Hosts[0].finish(nil)
+	rep.Hosts[1].finish(errors.New("dial tcp: connection refused"))
+
+	card := rep.card([]string{"/var/www", "/var/backup"})
+	assert.Equal(t, "AdaptiveCard", card.Type)
+	assert.Len(t, card.Body, 4)
+	assert.Equal(t, "drone-scp failure", card.Body[0].Text)
+	assert.Equal(t, cardFact{Title: "Files", Value: "3"}, card.Body[1].Facts[2])
+
+	a := card.Body[2]
+	assert.Equal(t, "a.example.com:22: success", a.Items[0].Text)
+	assert.Equal(t, "good", a.Items[0].Color)
+	assert.Equal(t, "/var/backup", a.Items[1].Facts[1].Title)
+
+	b := card.Body[3]
+	assert.Equal(t, "attention", b.Items[0].Color)
+	assert.Equal(t, "dial tcp: connection refused", b.Items[1].Text)
+	assert.Equal(t, "failure (0.0s)", b.Items[2].Facts[0].Value)
+
+	file := filepath.Join(t.TempDir(), "card.json")
+	assert.NoError(t, rep.writeCard(file, []string{"/var/www", "/var/backup"}))
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+
+	var result adaptiveCard
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, card, result)
+}
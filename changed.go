@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+var (
+	errChangedSinceWithSync      = errors.New("changed since can't be combined with sync or incremental mode")
+	errChangedSinceUnsupportedOS = errors.New("removing the files deleted in git is not supported on windows host")
+)
+
+const (
+	// autoRevision diffs against the commit recorded in the targets, or the
+	// previous commit from the CI environment.
+	autoRevision = "auto"
+	// revisionName is written into every target folder with the commit
+	// deployed there by a successful transfer.
+	revisionName = ".drone-scp-revision"
+)
+
+// gitChanges lists the files changed and deleted since a revision, named
+// relative to the working directory.
+type gitChanges struct {
+	Revision string
+	Changed  map[string]bool
+	Deleted  []string
+}
+
+// deployedRevision returns the commit recorded in every target folder, or an
+// empty string when a target has none or the targets differ. found reports
+// whether any target recorded a commit.
+func (p *Plugin) deployedRevision(hosts []string) (revision string, found bool) {
+	missing := false
+	for _, h := range hosts {
+		ssh := p.sshConfig(h)
+		if p.systemType(ssh) != "unix" {
+			missing = true
+			continue
+		}
+		for _, target := range p.Config.Target {
+			target = strings.ReplaceAll(target, " ", "\\ ")
+			outStr, _, _, err := p.run(ssh, catcmd("unix", target+"/"+revisionName))
+			rev := strings.TrimSpace(outStr)
+			switch {
+			case err != nil || rev == "":
+				missing = true
+			case revision != "" && rev != revision:
+				return "", true
+			default:
+				revision, found = rev, true
+			}
+		}
+	}
+	if missing {
+		return "", found
+	}
+	return revision, found
+}
+
+// previousRevision returns the commit built before the current one, as
+// provided by Drone, Woodpecker, GitLab or GitHub Actions.
+func previousRevision() string {
+	for _, name := range []string{"DRONE_COMMIT_BEFORE", "CI_PREV_COMMIT_SHA", "CI_COMMIT_BEFORE_SHA"} {
+		if rev := strings.TrimSpace(os.Getenv(name)); rev != "" {
+			return rev
+		}
+	}
+
+	if file := os.Getenv("GITHUB_EVENT_PATH"); file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return ""
+		}
+		var event struct {
+			Before string `json:"before"`
+		}
+		if err := json.Unmarshal(content, &event); err == nil {
+			return strings.TrimSpace(event.Before)
+		}
+	}
+	return ""
+}
+
+// isZeroRevision reports whether rev is the null commit CI sends for the
+// first push of a branch.
+func isZeroRevision(rev string) bool {
+	return strings.Trim(rev, "0") == ""
+}
+
+// writeRevision records the deployed commit in the target folder.
+func (p *Plugin) writeRevision(systemType string, ssh *easyssh.MakeConfig, target string) error {
+	if systemType != "unix" {
+		return nil
+	}
+	data := p.revision + "\n"
+	return p.retry(ssh.Server, "write revision", func() error {
+		return ssh.WriteFile(strings.NewReader(data), int64(len(data)), target+"/"+revisionName)
+	})
+}
+
+// parseNameStatus parses the output of git diff --name-status -z --no-renames.
+func parseNameStatus(out string) (map[string]bool, []string) {
+	changed := map[string]bool{}
+	var deleted []string
+
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		status, name := fields[i], fields[i+1]
+		if strings.HasPrefix(status, "D") {
+			deleted = append(deleted, name)
+			continue
+		}
+		changed[name] = true
+	}
+	return changed, deleted
+}
+
+// gitChanges asks git for the files changed between the configured revision
+// and HEAD. It returns nil when every file is copied.
+func (p *Plugin) gitChanges(hosts []string) (*gitChanges, error) {
+	rev := strings.TrimSpace(p.Config.ChangedSince)
+	if rev == "" {
+		return nil, nil
+	}
+	if p.Config.Sync || p.Config.Incremental {
+		return nil, errChangedSinceWithSync
+	}
+
+	// HEAD is recorded in the targets once they are deployed
+	if out, err := exec.Command("git", "rev-parse", "--verify", "HEAD").Output(); err == nil {
+		p.revision = strings.TrimSpace(string(out))
+	}
+
+	if rev == autoRevision {
+		deployed, found := p.deployedRevision(hosts)
+		switch {
+		case deployed != "":
+			rev = deployed
+		case found:
+			fmt.Println("targets recorded different revisions, copy all files")
+			return nil, nil
+		default:
+			// targets deployed before revisions were recorded
+			if rev = previousRevision(); rev == "" || isZeroRevision(rev) {
+				fmt.Println("no previous commit found, copy all files")
+				return nil, nil
+			}
+		}
+		if err := exec.Command("git", "cat-file", "-e", rev+"^{commit}").Run(); err != nil {
+			fmt.Printf("revision %s is missing from the clone, copy all files\n", rev)
+			return nil, nil
+		}
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "-c", "core.quotepath=off", "diff", "--name-status", "-z", "--no-renames", "--relative", rev, "HEAD", "--")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// shallow clones may miss the revision
+		return nil, fmt.Errorf("git diff %s: %s", rev, strings.TrimSpace(stderr.String()))
+	}
+
+	changed, deleted := parseNameStatus(string(out))
+	fmt.Printf("%d files changed and %d files deleted since %s\n", len(changed), len(deleted), rev)
+	return &gitChanges{Revision: rev, Changed: changed, Deleted: deleted}, nil
+}
+
+// remoteNames returns the names below a target of the deleted files which
+// were copied from one of the sources.
+func (c *gitChanges) remoteNames(sources []string, files fileList, strip int) []string {
+	if c == nil {
+		return nil
+	}
+
+	var names []string
+	for _, name := range c.Deleted {
+		if isIgnored(filepath.FromSlash(name), files.Ignore) || files.Rules.ignored(name, false) {
+			continue
+		}
+		for _, source := range sources {
+			if strings.HasPrefix(source, "!") || filepath.IsAbs(source) {
+				continue
+			}
+			pattern := path.Clean(filepath.ToSlash(source))
+			if !sourceMatch(pattern, name) {
+				continue
+			}
+			// keep the "./" prefix tar writes for such sources
+			member := name
+			if source == "." || strings.HasPrefix(source, "./") {
+				member = "./" + name
+			}
+			if member = stripComponents(member, strip); member != "" {
+				names = append(names, member)
+			}
+			break
+		}
+	}
+	return names
+}
+
+// sourceMatch reports whether the source pattern matches name or one of its folders.
+func sourceMatch(pattern, name string) bool {
+	if pattern == "." {
+		return true
+	}
+	for p := name; p != "." && p != "/"; p = path.Dir(p) {
+		if ok, _ := doublestar.Match(pattern, p); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// deletedFiles returns the remote names of the files deleted in git, when
+// they should be removed from the targets.
+func (p *Plugin) deletedFiles(files fileList) []string {
+	if !p.Config.ChangedSinceDelete {
+		return nil
+	}
+	return p.changes.remoteNames(trimValues(p.Config.Source), files, p.Config.StripComponents)
+}
+
+// removeDeletedFiles removes the files deleted in git from the target folder.
+func (p *Plugin) removeDeletedFiles(systemType string, ssh *easyssh.MakeConfig, target string, names []string) error {
+	if systemType == "windows" {
+		return errChangedSinceUnsupportedOS
+	}
+	if err := p.removeRemoteFiles(systemType, ssh, target, names); err != nil {
+		return err
+	}
+
+	p.log(ssh.Server, fmt.Sprintf("removed %d files from %s deleted since %s", len(names), target, p.changes.Revision))
+	if p.Config.Debug {
+		for _, name := range names {
+			p.log(ssh.Server, "-", name)
+		}
+	}
+	return nil
+}
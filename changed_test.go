@@ -0,0 +1,140 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNameStatus(t *testing.T) {
+	changed, deleted := parseNameStatus("M\x00web/a.html\x00A\x00web/new file.html\x00D\x00web/old.html\x00T\x00web/link\x00")
+	assert.Equal(t, map[string]bool{"web/a.html": true, "web/new file.html": true, "web/link": true}, changed)
+	assert.Equal(t, []string{"web/old.html"}, deleted)
+
+	changed, deleted = parseNameStatus("")
+	assert.Empty(t, changed)
+	assert.Empty(t, deleted)
+}
+
+func TestPreviousRevision(t *testing.T) {
+	for _, name := range []string{"DRONE_COMMIT_BEFORE", "CI_PREV_COMMIT_SHA", "CI_COMMIT_BEFORE_SHA", "GITHUB_EVENT_PATH"} {
+		t.Setenv(name, "")
+	}
+	assert.Equal(t, "", previousRevision())
+
+	event := filepath.Join(t.TempDir(), "event.json")
+	assert.NoError(t, os.WriteFile(event, []byte(`{"before": "abc123", "after": "def456"}`), 0o600))
+	t.Setenv("GITHUB_EVENT_PATH", event)
+	assert.Equal(t, "abc123", previousRevision())
+
+	t.Setenv("CI_COMMIT_BEFORE_SHA", "gitlab")
+	assert.Equal(t, "gitlab", previousRevision())
+
+	t.Setenv("DRONE_COMMIT_BEFORE", "drone")
+	assert.Equal(t, "drone", previousRevision())
+
+	assert.True(t, isZeroRevision("0000000000000000000000000000000000000000"))
+	assert.False(t, isZeroRevision("abc123"))
+}
+
+func TestGitChanges(t *testing.T) {
+	for _, name := range []string{"DRONE_COMMIT_BEFORE", "CI_PREV_COMMIT_SHA", "CI_COMMIT_BEFORE_SHA", "GITHUB_EVENT_PATH"} {
+		t.Setenv(name, "")
+	}
+
+	p := Plugin{}
+	changes, err := p.gitChanges(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, changes)
+	assert.Empty(t, p.revision)
+
+	// HEAD is recorded once the targets are deployed
+	p.Config.ChangedSince = "HEAD"
+	changes, err = p.gitChanges(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, changes.Changed)
+	assert.Len(t, p.revision, 40)
+
+	// no revision was read from an unreachable host
+	p.Config = Config{
+		Port:           22,
+		Protocol:       easyssh.PROTOCOL_TCP,
+		Username:       "drone-scp",
+		Password:       "1234",
+		Timeout:        time.Second,
+		CommandTimeout: time.Second,
+		Target:         []string{"/var/www"},
+		ChangedSince:   "auto",
+	}
+	changes, err = p.gitChanges([]string{"127.0.0.1:1"})
+	assert.NoError(t, err)
+	assert.Nil(t, changes)
+
+	// without a recorded revision the previous commit from CI is used
+	t.Setenv("DRONE_COMMIT_BEFORE", "0000000000000000000000000000000000000000")
+	changes, err = p.gitChanges([]string{"127.0.0.1:1"})
+	assert.NoError(t, err)
+	assert.Nil(t, changes)
+
+	t.Setenv("DRONE_COMMIT_BEFORE", p.revision)
+	changes, err = p.gitChanges([]string{"127.0.0.1:1"})
+	assert.NoError(t, err)
+	assert.Equal(t, p.revision, changes.Revision)
+
+	p.Config.ChangedSince = "no-such-revision"
+	_, err = p.gitChanges(nil)
+	assert.ErrorContains(t, err, "git diff no-such-revision")
+
+	p.Config.Sync = true
+	_, err = p.gitChanges(nil)
+	assert.Equal(t, errChangedSinceWithSync, err)
+}
+
+func TestRemoteNames(t *testing.T) {
+	changes := &gitChanges{Deleted: []string{
+		"app/web/old.html",
+		"app/web/css/old.css",
+		"app/web/old.map",
+		"app/api/main.go",
+		"docs/readme.md",
+	}}
+	files := fileList{Rules: parseIgnoreRules([]string{"*.map"})}
+
+	assert.Equal(t, []string{"web/old.html", "web/css/old.css"}, changes.remoteNames([]string{"app/web"}, files, 1))
+	assert.Equal(t, []string{"./app/web/old.html", "./app/web/css/old.css"}, changes.remoteNames([]string{"./app/web"}, files, 0))
+	assert.Equal(t, []string{"old.css"}, changes.remoteNames([]string{"app/**/*.css", "!app/web"}, files, 3))
+	assert.Equal(t, []string{"./app/api/main.go", "./docs/readme.md"}, changes.remoteNames([]string{"."}, fileList{Ignore: []string{"app/web"}}, 0))
+	assert.Empty(t, changes.remoteNames([]string{"/srv/app"}, files, 0))
+
+	var none *gitChanges
+	assert.Nil(t, none.remoteNames([]string{"app"}, files, 0))
+}
+
+func TestFileFilterChanged(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer func() { assert.NoError(t, os.Chdir(wd)) }()
+
+	for _, name := range []string{"web/a.html", "web/b.html", "web/css/app.css"} {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		assert.NoError(t, os.WriteFile(name, []byte("x"), 0o600))
+	}
+
+	p := Plugin{changes: &gitChanges{Revision: "abc123", Changed: map[string]bool{"web/a.html": true}}}
+	filter, err := p.fileFilter()
+	assert.NoError(t, err)
+
+	list, err := walkFiles(fileList{Source: []string{"web"}, Filter: filter}, false)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range list {
+		names = append(names, f.Name)
+	}
+	assert.Equal(t, []string{"web", "web/a.html"}, names)
+}
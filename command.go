@@ -1,5 +1,10 @@
 package main
 
+import (
+	"strconv"
+	"strings"
+)
+
 // This function returns the appropriate command for removing a file/directory based on the operating system.
 func rmcmd(os, target string) string {
 	switch os {
@@ -27,3 +32,138 @@ func mkdircmd(os, target string) string {
 	// Return an empty string if the operating system is not recognized
 	return ""
 }
+
+// This function returns the command creating a temporary folder, only accessible by the owner, and printing its path.
+func mktempcmd(os, prefix string) string {
+	switch os {
+	case "unix":
+		// Use the default temporary folder of the host without a prefix
+		if prefix == "" {
+			prefix = "${TMPDIR:-/tmp}/"
+		}
+		return "mktemp -d " + prefix + "drone-scp.XXXXXX"
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// This function returns the command listing every entry below the target as "type, size, mtime and relative path".
+func listcmd(os, target string) string {
+	switch os {
+	case "unix":
+		// GNU find prints one tab separated line per entry
+		return "find " + target + " -mindepth 1 -printf '%y\\t%s\\t%T@\\t%P\\n'"
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// This function returns the command removing the given entries relative to the target.
+func rmfilescmd(os, target string, files []string) string {
+	switch os {
+	case "unix":
+		// Change into the target first so the relative names are resolved there
+		return "cd " + target + " && rm -rf -- " + shellQuote(files...)
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// shellQuote wraps every value in single quotes for a POSIX shell.
+func shellQuote(values ...string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, "'"+strings.ReplaceAll(v, "'", `'\''`)+"'")
+	}
+	return strings.Join(quoted, " ")
+}
+
+// This function returns the command printing a file, printing nothing if the file does not exist.
+func catcmd(os, file string) string {
+	switch os {
+	case "unix":
+		return "if [ -f " + file + " ]; then cat " + file + "; fi"
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// This function returns the command executed on the relay host to copy a file to another host.
+// A positive limit in Kbit/s is passed on to scp. The host key must be listed in knownHosts when given,
+// otherwise it is accepted on first use.
+func relaycmd(src, user, host, port, dest, keyPath, knownHosts string, limit int64) string {
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	cmd := "scp -o BatchMode=yes -o StrictHostKeyChecking=accept-new -P " + port
+	if knownHosts != "" {
+		cmd = "scp -o BatchMode=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=" + knownHosts + " -P " + port
+	}
+	if keyPath != "" {
+		cmd += " -i " + keyPath
+	}
+	if limit > 0 {
+		cmd += " -l " + strconv.FormatInt(limit, 10)
+	}
+	return cmd + " " + src + " " + user + "@" + host + ":" + dest
+}
+
+// This function returns the command moving a finished upload of the given size into place. A retry after
+// the connection dropped behind a successful move finds the destination already in place and succeeds.
+func mvcmd(src, dest string, size int64) string {
+	return "mv " + src + " " + dest + " || { [ ! -e " + src + " ] && [ \"$(wc -c < " + dest + ")\" -eq " +
+		strconv.FormatInt(size, 10) + " ]; }"
+}
+
+// This function returns the command creating the lock folder owned by owner. The owner takes its own lock
+// again, and a lock of another owner not touched for the given minutes is taken over.
+func lockcmd(lock, owner string, stale int) string {
+	file := lock + "/owner"
+	take := "mkdir " + lock + " && echo " + shellQuote(owner) + " > " + file
+	return take + " 2>/dev/null || [ \"$(cat " + file + " 2>/dev/null)\" = " + shellQuote(owner) + " ] || " +
+		"{ [ -n \"$(find " + lock + " -maxdepth 0 -mmin +" + strconv.Itoa(stale) + ")\" ] && rm -rf " + lock + " && " + take + "; }"
+}
+
+// This function returns the command printing the SHA-256 checksum of a file.
+func sha256cmd(os, file string) string {
+	switch os {
+	case "windows":
+		return "powershell -NoProfile -Command \"(Get-FileHash -Algorithm SHA256 -LiteralPath '" + file + "').Hash\""
+	case "unix":
+		// Fall back to shasum where coreutils are missing
+		return "sha256sum " + file + " 2>/dev/null || shasum -a 256 " + file
+	}
+	// Return an empty string if the operating system is not recognized
+	return ""
+}
+
+// This function returns the command printing the SHA-256 checksums of the files relative to the target.
+func sha256filescmd(os, target string, files []string) string {
+	switch os {
+	case "unix":
+		return "cd " + target + " && sha256sum -- " + shellQuote(files...)
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// This function returns the command printing the size of a file, 0 if it does not exist.
+func sizecmd(os, file string) string {
+	switch os {
+	case "unix":
+		return "if [ -f " + file + " ]; then wc -c < " + file + "; else echo 0; fi"
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
+
+// This function returns the command checking that a folder can be written or created.
+func writablecmd(os, target string) string {
+	switch os {
+	case "unix":
+		// Walk up to the closest existing folder, which mkdir -p would create the target in
+		return "d=" + target + "; while [ ! -e \"$d\" ]; do d=$(dirname \"$d\"); done; [ -d \"$d\" ] && [ -w \"$d\" ]"
+	}
+	// Return an empty string if the operating system is not supported
+	return ""
+}
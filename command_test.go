@@ -40,3 +40,115 @@ func TestCommands(t *testing.T) {
 		t.Errorf("mkdircmd(%s, %s) = %s; expected %s", os4, target4, actual4, expected4)
 	}
 }
+
+func TestSyncCommands(t *testing.T) {
+	expected := "find /var/www -mindepth 1 -printf '%y\\t%s\\t%T@\\t%P\\n'"
+	if actual := listcmd("unix", "/var/www"); actual != expected {
+		t.Errorf("listcmd(unix, /var/www) = %s; expected %s", actual, expected)
+	}
+
+	if actual := listcmd("windows", "C:\\www"); actual != "" {
+		t.Errorf("listcmd(windows, C:\\www) = %s; expected empty string", actual)
+	}
+
+	expected = "cd /var/www && rm -rf -- 'a.txt' 'it'\\''s.txt'"
+	if actual := rmfilescmd("unix", "/var/www", []string{"a.txt", "it's.txt"}); actual != expected {
+		t.Errorf("rmfilescmd(unix, /var/www) = %s; expected %s", actual, expected)
+	}
+}
+
+func TestCatCommand(t *testing.T) {
+	expected := "if [ -f /var/www/a.json ]; then cat /var/www/a.json; fi"
+	if actual := catcmd("unix", "/var/www/a.json"); actual != expected {
+		t.Errorf("catcmd(unix, /var/www/a.json) = %s; expected %s", actual, expected)
+	}
+}
+
+func TestRelayCommand(t *testing.T) {
+	expected := "scp -o BatchMode=yes -o StrictHostKeyChecking=accept-new -P 22 /tmp/a.tar.gz.relay deploy@10.0.0.2:/tmp/a.tar.gz"
+	if actual := relaycmd("/tmp/a.tar.gz.relay", "deploy", "10.0.0.2", "22", "/tmp/a.tar.gz", "", "", 0); actual != expected {
+		t.Errorf("relaycmd = %s; expected %s", actual, expected)
+	}
+
+	expected = "scp -o BatchMode=yes -o StrictHostKeyChecking=accept-new -P 2222 -i ~/.ssh/deploy a.tar.gz.relay deploy@[fe80::1]:a.tar.gz"
+	if actual := relaycmd("a.tar.gz.relay", "deploy", "fe80::1", "2222", "a.tar.gz", "~/.ssh/deploy", "", 0); actual != expected {
+		t.Errorf("relaycmd = %s; expected %s", actual, expected)
+	}
+
+	expected = "scp -o BatchMode=yes -o StrictHostKeyChecking=accept-new -P 22 -l 160000 /tmp/a.tar.gz.relay deploy@10.0.0.2:/tmp/a.tar.gz"
+	if actual := relaycmd("/tmp/a.tar.gz.relay", "deploy", "10.0.0.2", "22", "/tmp/a.tar.gz", "", "", 160000); actual != expected {
+		t.Errorf("relaycmd = %s; expected %s", actual, expected)
+	}
+
+	expected = "scp -o BatchMode=yes -o StrictHostKeyChecking=yes -o UserKnownHostsFile=/tmp/a.tar.gz.relay.known_hosts -P 22 /tmp/a.tar.gz.relay deploy@10.0.0.2:/tmp/a.tar.gz"
+	if actual := relaycmd("/tmp/a.tar.gz.relay", "deploy", "10.0.0.2", "22", "/tmp/a.tar.gz", "", "/tmp/a.tar.gz.relay.known_hosts", 0); actual != expected {
+		t.Errorf("relaycmd = %s; expected %s", actual, expected)
+	}
+}
+
+func TestChecksumCommands(t *testing.T) {
+	expected := "sha256sum /tmp/a.tar.gz 2>/dev/null || shasum -a 256 /tmp/a.tar.gz"
+	if actual := sha256cmd("unix", "/tmp/a.tar.gz"); actual != expected {
+		t.Errorf("sha256cmd(unix) = %s; expected %s", actual, expected)
+	}
+
+	expected = "powershell -NoProfile -Command \"(Get-FileHash -Algorithm SHA256 -LiteralPath 'C:\\tmp\\a.tar.gz').Hash\""
+	if actual := sha256cmd("windows", "C:\\tmp\\a.tar.gz"); actual != expected {
+		t.Errorf("sha256cmd(windows) = %s; expected %s", actual, expected)
+	}
+
+	expected = "cd /var/www && sha256sum -- 'index.html' 'css/app.css'"
+	if actual := sha256filescmd("unix", "/var/www", []string{"index.html", "css/app.css"}); actual != expected {
+		t.Errorf("sha256filescmd(unix) = %s; expected %s", actual, expected)
+	}
+}
+
+func TestSizeCommand(t *testing.T) {
+	expected := "if [ -f /tmp/a.part ]; then wc -c < /tmp/a.part; else echo 0; fi"
+	if actual := sizecmd("unix", "/tmp/a.part"); actual != expected {
+		t.Errorf("sizecmd(unix) = %s; expected %s", actual, expected)
+	}
+}
+
+func TestWritableCommand(t *testing.T) {
+	expected := "d=/var/www/my\\ app; while [ ! -e \"$d\" ]; do d=$(dirname \"$d\"); done; [ -d \"$d\" ] && [ -w \"$d\" ]"
+	if actual := writablecmd("unix", "/var/www/my\\ app"); actual != expected {
+		t.Errorf("writablecmd(unix, /var/www/my\\ app) = %s; expected %s", actual, expected)
+	}
+
+	if actual := writablecmd("windows", "C:\\www"); actual != "" {
+		t.Errorf("writablecmd(windows, C:\\www) = %s; expected empty", actual)
+	}
+}
+
+func TestMktempCommand(t *testing.T) {
+	expected := "mktemp -d /data/tmp/drone-scp.XXXXXX"
+	if actual := mktempcmd("unix", "/data/tmp/"); actual != expected {
+		t.Errorf("mktempcmd(unix) = %s; expected %s", actual, expected)
+	}
+
+	expected = "mktemp -d ${TMPDIR:-/tmp}/drone-scp.XXXXXX"
+	if actual := mktempcmd("unix", ""); actual != expected {
+		t.Errorf("mktempcmd(unix) = %s; expected %s", actual, expected)
+	}
+
+	if actual := mktempcmd("windows", ""); actual != "" {
+		t.Errorf("mktempcmd(windows) = %s; expected an empty string", actual)
+	}
+}
+
+func TestMoveCommand(t *testing.T) {
+	expected := "mv /tmp/a.part /tmp/x.tar || { [ ! -e /tmp/a.part ] && [ \"$(wc -c < /tmp/x.tar)\" -eq 1024 ]; }"
+	if actual := mvcmd("/tmp/a.part", "/tmp/x.tar", 1024); actual != expected {
+		t.Errorf("mvcmd = %s; expected %s", actual, expected)
+	}
+}
+
+func TestLockCommand(t *testing.T) {
+	expected := "mkdir /tmp/a.part.lock && echo '/tmp/x.tar' > /tmp/a.part.lock/owner 2>/dev/null || " +
+		"[ \"$(cat /tmp/a.part.lock/owner 2>/dev/null)\" = '/tmp/x.tar' ] || " +
+		"{ [ -n \"$(find /tmp/a.part.lock -maxdepth 0 -mmin +10)\" ] && rm -rf /tmp/a.part.lock && mkdir /tmp/a.part.lock && echo '/tmp/x.tar' > /tmp/a.part.lock/owner; }"
+	if actual := lockcmd("/tmp/a.part.lock", "/tmp/x.tar", 10); actual != expected {
+		t.Errorf("lockcmd = %s; expected %s", actual, expected)
+	}
+}
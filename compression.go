@@ -0,0 +1,180 @@
+package main
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/klauspost/compress/zstd"
+	"github.com/klauspost/pgzip"
+)
+
+var errInvalidCompression = errors.New("compression must be one of gzip, zstd, xz, bzip2 or none")
+
+// compression describes how tar compresses and extracts the archive.
+type compression struct {
+	Name string
+	// Ext is the file extension of the archive.
+	Ext string
+	// Flag is the tar option selecting the compression.
+	Flag string
+	// Program is the external compressor tar runs.
+	Program string
+}
+
+var compressions = map[string]compression{
+	"gzip":  {Name: "gzip", Ext: ".tar.gz", Flag: "-z", Program: "gzip"},
+	"zstd":  {Name: "zstd", Ext: ".tar.zst", Flag: "--zstd", Program: "zstd"},
+	"xz":    {Name: "xz", Ext: ".tar.xz", Flag: "-J", Program: "xz"},
+	"bzip2": {Name: "bzip2", Ext: ".tar.bz2", Flag: "-j", Program: "bzip2"},
+	"none":  {Name: "none", Ext: ".tar"},
+}
+
+// compression returns the configured compression, gzip by default.
+func (p *Plugin) compression() (compression, error) {
+	name := strings.ToLower(strings.TrimSpace(p.Config.Compression))
+	if name == "" {
+		name = "gzip"
+	}
+	c, ok := compressions[name]
+	if !ok {
+		return c, errInvalidCompression
+	}
+	return c, nil
+}
+
+// createArgs returns the tar options creating an archive with the compression level.
+func (c compression) createArgs(level int) []string {
+	if c.Program != "" && level > 0 {
+		return []string{"--use-compress-program", c.Program + " -" + strconv.Itoa(level), "-cf"}
+	}
+	return c.args("cf")
+}
+
+// extractArgs returns the tar options extracting an archive.
+func (c compression) extractArgs() []string {
+	return c.args("xf")
+}
+
+func (c compression) args(mode string) []string {
+	switch {
+	case c.Flag == "":
+		return []string{"-" + mode}
+	case strings.HasPrefix(c.Flag, "--"):
+		return []string{c.Flag, "-" + mode}
+	}
+	// combine the short options, e.g. -zcf
+	return []string{c.Flag + mode}
+}
+
+// hostCompression checks the remote tar can extract the configured compression
+// and falls back to gzip otherwise.
+func (p *Plugin) hostCompression(systemType string, ssh *easyssh.MakeConfig, c compression) compression {
+	if c.Name == "gzip" || c.Name == "none" || systemType == "windows" {
+		return c
+	}
+
+	cmd := fmt.Sprintf("%s %s -cf /dev/null -T /dev/null", p.Config.TarExec, c.Flag)
+	if _, errStr, _, err := p.run(ssh, cmd); err != nil || errStr != "" {
+		p.log(ssh.Server, fmt.Sprintf("remote tar lacks %s support, fall back to gzip", c.Name))
+		return compressions["gzip"]
+	}
+	return c
+}
+
+// parallel reports whether the archive is compressed by multiple workers.
+func (p *Plugin) parallel(c compression) bool {
+	return p.Config.CompressionWorkers > 1 && (c.Name == "gzip" || c.Name == "zstd")
+}
+
+// newParallelWriter returns a gzip or zstd writer compressing blocks concurrently.
+func newParallelWriter(w io.Writer, c compression, level, workers int) (io.WriteCloser, error) {
+	switch c.Name {
+	case "gzip":
+		if level <= 0 {
+			level = gzip.DefaultCompression
+		}
+		gz, err := pgzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, err
+		}
+		return gz, gz.SetConcurrency(1<<20, workers)
+	case "zstd":
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(workers)}
+		if level > 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		return zstd.NewWriter(w, opts...)
+	}
+	return nil, errInvalidCompression
+}
+
+// createArchive writes the files into the archive src. The options in front are
+// passed to tar as they are.
+func (p *Plugin) createArchive(c compression, src string, front []string, files fileList) error {
+	if files.selected() {
+		// tar can't re-include excluded entries or filter, pass the selected files
+		list, err := walkFiles(files, p.Config.TarDereference)
+		if err != nil {
+			return err
+		}
+		name, err := writeFileList(list)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(name)
+		front = append(front, "--null", "--no-recursion", "-T", getRealPath(name))
+		files = fileList{}
+	}
+
+	if !p.parallel(c) {
+		// only the compressed size is known while tar is running
+		pr := p.startSampledProgress("", "archive", 0, fileSize(src))
+		defer pr.Stop()
+		return p.runTar(append(front, p.tarArgs(c, src, files)...))
+	}
+
+	out, err := os.Create(src)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w, err := newParallelWriter(out, c, p.Config.CompressionLevel, p.Config.CompressionWorkers)
+	if err != nil {
+		return err
+	}
+
+	// tar writes the plain archive to stdout
+	cmd := exec.Command(p.Config.TarExec, append(front, p.tarArgs(compressions["none"], "-", files)...)...)
+	if p.Config.Debug {
+		fmt.Println("$", strings.Join(cmd.Args, " "), "|", c.Name, "with", p.Config.CompressionWorkers, "workers")
+	}
+	var total int64
+	if p.Config.ProgressInterval > 0 {
+		list, _ := walkFiles(files, p.Config.TarDereference)
+		for _, f := range list {
+			total += f.Size
+		}
+	}
+	pr := p.startProgress("", "archive", total)
+	defer pr.Stop()
+
+	cmd.Stdout = io.MultiWriter(w, pr)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression(t *testing.T) {
+	p := Plugin{}
+	c, err := p.compression()
+	assert.NoError(t, err)
+	assert.Equal(t, "gzip", c.Name)
+	assert.Equal(t, ".tar.gz", c.Ext)
+
+	p.Config.Compression = " ZSTD "
+	c, err = p.compression()
+	assert.NoError(t, err)
+	assert.Equal(t, "zstd", c.Name)
+	assert.Equal(t, ".tar.zst", c.Ext)
+
+	p.Config.Compression = "lz4"
+	_, err = p.compression()
+	assert.Equal(t, errInvalidCompression, err)
+}
+
+func TestCompressionArgs(t *testing.T) {
+	assert.Equal(t, []string{"-zcf"}, compressions["gzip"].createArgs(0))
+	assert.Equal(t, []string{"--use-compress-program", "xz -6", "-cf"}, compressions["xz"].createArgs(6))
+	assert.Equal(t, []string{"-cf"}, compressions["none"].createArgs(9))
+	assert.Equal(t, []string{"-jxf"}, compressions["bzip2"].extractArgs())
+	assert.Equal(t, []string{"--zstd", "-xf"}, compressions["zstd"].extractArgs())
+}
+
+func TestParallelArchive(t *testing.T) {
+	for _, name := range []string{"gzip", "zstd"} {
+		t.Run(name, func(t *testing.T) {
+			p := Plugin{
+				Config: Config{
+					TarExec:            "tar",
+					Compression:        name,
+					CompressionWorkers: 4,
+				},
+			}
+			c, err := p.compression()
+			assert.NoError(t, err)
+			assert.True(t, p.parallel(c))
+
+			src := filepath.Join(t.TempDir(), "archive"+c.Ext)
+			err = p.createArchive(c, src, nil, globList([]string{"tests/*.txt"}))
+			assert.NoError(t, err)
+
+			f, err := os.Open(src)
+			assert.NoError(t, err)
+			defer f.Close()
+
+			var r io.Reader
+			if name == "gzip" {
+				gz, err := gzip.NewReader(f)
+				assert.NoError(t, err)
+				r = gz
+			} else {
+				zr, err := zstd.NewReader(f)
+				assert.NoError(t, err)
+				defer zr.Close()
+				r = zr
+			}
+
+			var names []string
+			tr := tar.NewReader(r)
+			for {
+				h, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				assert.NoError(t, err)
+				names = append(names, h.Name)
+			}
+			assert.Equal(t, []string{"tests/a.txt", "tests/b.txt"}, names)
+		})
+	}
+
+	p := Plugin{Config: Config{CompressionWorkers: 4}}
+	assert.False(t, p.parallel(compressions["xz"]))
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	errMissingJobs   = errors.New("config file defines no jobs")
+	errDuplicateJobs = errors.New("config file defines the same job name twice")
+
+	// envPattern matches ${VAR} and the escaped $${VAR}
+	envPattern = regexp.MustCompile(`\$?\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+)
+
+type (
+	// configFile describes the jobs of a deploy.yml file.
+	configFile struct {
+		Parallel bool        `yaml:"parallel"`
+		Defaults yaml.Node   `yaml:"defaults"`
+		Jobs     []yaml.Node `yaml:"jobs"`
+	}
+
+	// jobSettings holds the settings of a job, named like the plugin settings.
+	jobSettings struct {
+		Name   string        `yaml:"name"`
+		Config Config        `yaml:",inline"`
+		Proxy  proxySettings `yaml:",inline"`
+	}
+
+	// proxySettings holds the proxy settings of a job.
+	proxySettings struct {
+		Host              string           `yaml:"proxy_host"`
+		Port              string           `yaml:"proxy_port"`
+		Protocol          easyssh.Protocol `yaml:"proxy_protocol"`
+		Username          string           `yaml:"proxy_username"`
+		Password          string           `yaml:"proxy_password"`
+		Key               string           `yaml:"proxy_key"`
+		KeyPath           string           `yaml:"proxy_key_path"`
+		Passphrase        string           `yaml:"proxy_passphrase"`
+		Fingerprint       string           `yaml:"proxy_fingerprint"`
+		Timeout           time.Duration    `yaml:"proxy_timeout"`
+		Ciphers           []string         `yaml:"proxy_ciphers"`
+		UseInsecureCipher bool             `yaml:"proxy_use_insecure_cipher"`
+	}
+
+	// job is a named copy job of the config file.
+	job struct {
+		Name   string
+		Plugin Plugin
+	}
+)
+
+func newProxySettings(c easyssh.DefaultConfig) proxySettings {
+	return proxySettings{
+		Host:              c.Server,
+		Port:              c.Port,
+		Protocol:          c.Protocol,
+		Username:          c.User,
+		Password:          c.Password,
+		Key:               c.Key,
+		KeyPath:           c.KeyPath,
+		Passphrase:        c.Passphrase,
+		Fingerprint:       c.Fingerprint,
+		Timeout:           c.Timeout,
+		Ciphers:           c.Ciphers,
+		UseInsecureCipher: c.UseInsecureCipher,
+	}
+}
+
+func (s proxySettings) config() easyssh.DefaultConfig {
+	return easyssh.DefaultConfig{
+		Server:            s.Host,
+		Port:              s.Port,
+		Protocol:          s.Protocol,
+		User:              s.Username,
+		Password:          s.Password,
+		Key:               s.Key,
+		KeyPath:           s.KeyPath,
+		Passphrase:        s.Passphrase,
+		Fingerprint:       s.Fingerprint,
+		Timeout:           s.Timeout,
+		Ciphers:           s.Ciphers,
+		UseInsecureCipher: s.UseInsecureCipher,
+	}
+}
+
+// expandEnv replaces ${VAR} in every value when VAR is set, keys are left
+// untouched. Plain values are resolved again, so "${DEBUG}" may become a boolean.
+func expandEnv(node *yaml.Node) {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if value := envPattern.ReplaceAllStringFunc(node.Value, expandVar); value != node.Value {
+			node.Value = value
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			expandEnv(node.Content[i])
+		}
+	default:
+		for _, n := range node.Content {
+			expandEnv(n)
+		}
+	}
+}
+
+// expandVar returns the value of a ${VAR} reference, which is kept when VAR
+// is not set. "$${VAR}" escapes the reference and becomes "${VAR}".
+func expandVar(ref string) string {
+	if strings.HasPrefix(ref, "$$") {
+		return ref[1:]
+	}
+	if value, ok := os.LookupEnv(ref[2 : len(ref)-1]); ok {
+		return value
+	}
+	return ref
+}
+
+// decode applies the settings of the node on top of s, rejecting unknown settings.
+func (s *jobSettings) decode(node *yaml.Node) error {
+	if node.Kind == 0 {
+		return nil
+	}
+	out, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(out))
+	dec.KnownFields(true)
+	return dec.Decode(s)
+}
+
+// loadJobs reads the jobs of the config file. Every job starts from the
+// settings given by flags, overridden by the defaults and its own settings.
+func loadJobs(file string, base Config) ([]job, bool, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", file, err)
+	}
+	expandEnv(&doc)
+
+	var cfg configFile
+	if err := doc.Decode(&cfg); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(cfg.Jobs) == 0 {
+		return nil, false, errMissingJobs
+	}
+
+	defaults := jobSettings{Config: base, Proxy: newProxySettings(base.Proxy)}
+	if err := defaults.decode(&cfg.Defaults); err != nil {
+		return nil, false, fmt.Errorf("%s: defaults: %w", file, err)
+	}
+	defaults.Name = ""
+
+	names := map[string]bool{}
+	jobs := make([]job, 0, len(cfg.Jobs))
+	for i := range cfg.Jobs {
+		settings := defaults
+		if err := settings.decode(&cfg.Jobs[i]); err != nil {
+			return nil, false, fmt.Errorf("%s: job %d: %w", file, i+1, err)
+		}
+		if settings.Name == "" {
+			settings.Name = fmt.Sprintf("job %d", i+1)
+		}
+		if names[settings.Name] {
+			return nil, false, fmt.Errorf("%w: %s", errDuplicateJobs, settings.Name)
+		}
+		names[settings.Name] = true
+
+		settings.Config.Proxy = settings.Proxy.config()
+		jobs = append(jobs, job{Name: settings.Name, Plugin: Plugin{Config: settings.Config}})
+	}
+	return jobs, cfg.Parallel, nil
+}
+
+// runJobs runs the jobs one after the other, stopping at the first failure,
+// or all at once when parallel is set. The reports are written once all jobs
+// are done.
+func runJobs(jobs []job, parallel bool) error {
+	for i := range jobs {
+		jobs[i].Plugin.collect = jobs[i].Plugin.reporting()
+	}
+	err := execJobs(jobs, parallel)
+	if err := writeJobReports(jobs); err != nil {
+		fmt.Println("drone-scp report error:", err)
+	}
+	return err
+}
+
+func execJobs(jobs []job, parallel bool) error {
+	if !parallel {
+		for i := range jobs {
+			j := &jobs[i]
+			fmt.Printf("job %s: start\n", j.Name)
+			if err := j.Plugin.Exec(); err != nil {
+				return fmt.Errorf("job %s: %w", j.Name, err)
+			}
+			fmt.Printf("job %s: done\n", j.Name)
+		}
+		return nil
+	}
+
+	errs := make([]error, len(jobs))
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			j := &jobs[i]
+			fmt.Printf("job %s: start\n", j.Name)
+			if err := j.Plugin.Exec(); err != nil {
+				errs[i] = fmt.Errorf("job %s: %w", j.Name, err)
+				fmt.Printf("job %s: failed\n", j.Name)
+				return
+			}
+			fmt.Printf("job %s: done\n", j.Name)
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// reportOutputs returns the settings naming a report file.
+var reportOutputs = []func(c *Config) *string{
+	func(c *Config) *string { return &c.ReportFile },
+	func(c *Config) *string { return &c.JUnitFile },
+	func(c *Config) *string { return &c.CardPath },
+	func(c *Config) *string { return &c.GitHubSummary },
+	func(c *Config) *string { return &c.GitHubOutput },
+}
+
+// combineJobs merges the reports of the jobs which ran and lists their targets.
+func combineJobs(jobs []*job) (*report, []string) {
+	var reports []*report
+	var targets []string
+	seen := map[string]bool{}
+	for _, j := range jobs {
+		if j.Plugin.result == nil {
+			continue
+		}
+		reports = append(reports, j.Plugin.result)
+		jobTargets := j.Plugin.Config.Target
+		if len(jobTargets) == 0 {
+			// a job copying mappings has the targets of its mappings
+			jobTargets = j.Plugin.result.targets()
+		}
+		for _, target := range jobTargets {
+			if !seen[target] {
+				seen[target] = true
+				targets = append(targets, target)
+			}
+		}
+	}
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	return mergeReports(reports), targets
+}
+
+// writeJobReports writes the report files of the jobs. The jobs sharing a
+// file get a combined report, so every file is written once.
+func writeJobReports(jobs []job) error {
+	var errs []error
+	for _, output := range reportOutputs {
+		var files []string
+		groups := map[string][]*job{}
+		for i := range jobs {
+			file := *output(&jobs[i].Plugin.Config)
+			if file == "" {
+				continue
+			}
+			if _, ok := groups[file]; !ok {
+				files = append(files, file)
+			}
+			groups[file] = append(groups[file], &jobs[i])
+		}
+
+		for _, file := range files {
+			rep, targets := combineJobs(groups[file])
+			if rep == nil {
+				continue
+			}
+			p := Plugin{Config: Config{Target: targets}}
+			*output(&p.Config) = file
+			errs = append(errs, p.writeReports(rep))
+		}
+	}
+	return errors.Join(errs...)
+}
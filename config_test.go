@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "deploy.yml")
+	assert.NoError(t, os.WriteFile(file, []byte(content), 0o600))
+	return file
+}
+
+func TestLoadJobs(t *testing.T) {
+	t.Setenv("DEPLOY_HOST", "example.com")
+	t.Setenv("DEPLOY_ROOT", "/var/www")
+	t.Setenv("DEPLOY_PARALLEL", "true")
+
+	file := writeConfig(t, `
+parallel: ${DEPLOY_PARALLEL}
+defaults:
+  host: ["${DEPLOY_HOST}"]
+  username: deploy
+  key_path: /root/.ssh/id_rsa
+  command_timeout: 2m
+  proxy_host: bastion.example.com
+jobs:
+  - name: web
+    source: [dist/*]
+    target: ["${DEPLOY_ROOT}/html"]
+    strip_components: 1
+  - source: [config/prod.env]
+    target: [/etc/app]
+    host: [db.example.com, cache.example.com]
+    sync: true
+`)
+
+	base := Config{Port: 22, TarExec: "tar", Host: []string{"flag.example.com"}}
+	base.Proxy.Port = "22"
+	jobs, parallel, err := loadJobs(file, base)
+	assert.NoError(t, err)
+	assert.True(t, parallel)
+	assert.Len(t, jobs, 2)
+
+	web := jobs[0]
+	assert.Equal(t, "web", web.Name)
+	assert.Equal(t, []string{"example.com"}, web.Plugin.Config.Host)
+	assert.Equal(t, "deploy", web.Plugin.Config.Username)
+	assert.Equal(t, 22, web.Plugin.Config.Port)
+	assert.Equal(t, "tar", web.Plugin.Config.TarExec)
+	assert.Equal(t, 2*time.Minute, web.Plugin.Config.CommandTimeout)
+	assert.Equal(t, []string{"/var/www/html"}, web.Plugin.Config.Target)
+	assert.Equal(t, 1, web.Plugin.Config.StripComponents)
+	assert.Equal(t, "bastion.example.com", web.Plugin.Config.Proxy.Server)
+	assert.Equal(t, "22", web.Plugin.Config.Proxy.Port)
+
+	config := jobs[1]
+	assert.Equal(t, "job 2", config.Name)
+	assert.Equal(t, []string{"db.example.com", "cache.example.com"}, config.Plugin.Config.Host)
+	assert.Equal(t, 0, config.Plugin.Config.StripComponents)
+	assert.True(t, config.Plugin.Config.Sync)
+	assert.False(t, web.Plugin.Config.Sync)
+}
+
+func TestLoadJobsLiteralDollar(t *testing.T) {
+	t.Setenv("DEPLOY_ROOT", "/var/www")
+
+	file := writeConfig(t, `
+jobs:
+  - password: pa$$w0rd
+    source: [$HOME/dist]
+    target: ["$${DEPLOY_ROOT}/html", "${DEPLOY_UNSET}/html"]
+    webhook_template: '{{ $h := .Hosts }}{{ len $h }}'
+`)
+
+	jobs, _, err := loadJobs(file, Config{})
+	assert.NoError(t, err)
+	assert.Equal(t, "pa$$w0rd", jobs[0].Plugin.Config.Password)
+	assert.Equal(t, []string{"$HOME/dist"}, jobs[0].Plugin.Config.Source)
+	assert.Equal(t, []string{"${DEPLOY_ROOT}/html", "${DEPLOY_UNSET}/html"}, jobs[0].Plugin.Config.Target)
+	assert.Equal(t, "{{ $h := .Hosts }}{{ len $h }}", jobs[0].Plugin.Config.WebhookTemplate)
+}
+
+func TestLoadJobsErrors(t *testing.T) {
+	_, _, err := loadJobs(writeConfig(t, "parallel: true\n"), Config{})
+	assert.Equal(t, errMissingJobs, err)
+
+	_, _, err = loadJobs(writeConfig(t, "jobs:\n  - name: web\n  - name: web\n"), Config{})
+	assert.ErrorIs(t, err, errDuplicateJobs)
+
+	_, _, err = loadJobs(writeConfig(t, "jobs:\n  - name: web\n    strip_component: 1\n"), Config{})
+	assert.ErrorContains(t, err, "job 1")
+	assert.ErrorContains(t, err, "strip_component")
+
+	_, _, err = loadJobs(filepath.Join(t.TempDir(), "missing.yml"), Config{})
+	assert.Error(t, err)
+}
+
+func TestRunJobs(t *testing.T) {
+	jobs := []job{
+		{Name: "web", Plugin: Plugin{Config: Config{Password: "secret"}}},
+		{Name: "api", Plugin: Plugin{Config: Config{Password: "secret"}}},
+	}
+
+	err := runJobs(jobs, false)
+	assert.ErrorIs(t, err, errMissingSourceOrTarget)
+	assert.ErrorContains(t, err, "job web")
+	assert.NotContains(t, err.Error(), "job api")
+
+	err = runJobs(jobs, true)
+	assert.ErrorContains(t, err, "job web")
+	assert.ErrorContains(t, err, "job api")
+}
+
+func TestRunJobsSharedReport(t *testing.T) {
+	dir := t.TempDir()
+	config := func(host, target string) Config {
+		return Config{
+			Host:           []string{host},
+			Port:           22,
+			Protocol:       easyssh.PROTOCOL_TCP,
+			Username:       "drone-scp",
+			Password:       "1234",
+			Timeout:        time.Second,
+			CommandTimeout: time.Second,
+			Source:         []string{"tests/a.txt"},
+			Target:         []string{target},
+			TarExec:        "tar",
+			TarTmpPath:     dir + "/",
+			ReportFile:     filepath.Join(dir, "report.json"),
+			JUnitFile:      filepath.Join(dir, "junit.xml"),
+			GitHubOutput:   filepath.Join(dir, "github_output"),
+		}
+	}
+	// a job copying mappings is reported with the other jobs
+	assets := config("127.0.0.3:1", "")
+	assets.Source, assets.Target = nil, nil
+	assets.Mapping = []string{"tests/b.txt -> /srv/assets/", "tests/global -> /srv/global/"}
+	jobs := []job{
+		{Name: "web", Plugin: Plugin{Config: config("127.0.0.1:1", "/var/www")}},
+		{Name: "api", Plugin: Plugin{Config: config("127.0.0.2:1", "/srv/api")}},
+		{Name: "assets", Plugin: Plugin{Config: assets}},
+	}
+
+	assert.Error(t, runJobs(jobs, true))
+
+	// both jobs end up in a single report
+	data, err := os.ReadFile(filepath.Join(dir, "report.json"))
+	assert.NoError(t, err)
+	var rep report
+	assert.NoError(t, json.Unmarshal(data, &rep))
+	assert.Equal(t, statusFailure, rep.Status)
+	assert.Equal(t, 3, rep.Files)
+	if assert.Len(t, rep.Hosts, 3) {
+		assert.Equal(t, "127.0.0.1", rep.Hosts[0].Host)
+		assert.Equal(t, "127.0.0.2", rep.Hosts[1].Host)
+		assert.Equal(t, "127.0.0.3", rep.Hosts[2].Host)
+	}
+
+	junit, err := os.ReadFile(filepath.Join(dir, "junit.xml"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(junit), `tests="3"`)
+	assert.Contains(t, string(junit), `name="/srv/assets/"`)
+
+	// the outputs are appended once for all jobs
+	output, err := os.ReadFile(filepath.Join(dir, "github_output"))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(output), "failed_hosts="))
+	assert.Contains(t, string(output), "127.0.0.3")
+}
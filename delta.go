@@ -0,0 +1,631 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/urfave/cli/v2"
+)
+
+// The delta transfer works like rsync: the remote host prints the signatures
+// of the fixed size blocks of its copy, the runner searches those blocks at
+// every offset of the new file with a rolling checksum and only sends the
+// bytes which do not match any block.
+
+var (
+	errDeltaWithoutIncremental = errors.New("delta transfer requires incremental mode")
+	errInvalidDeltaArgs        = errors.New("invalid delta helper arguments")
+	errInvalidSignature        = errors.New("invalid delta signature")
+	errInvalidDelta            = errors.New("invalid delta file")
+	errDeltaChecksum           = errors.New("delta checksum mismatch after patch")
+)
+
+const (
+	deltaMagic = "DSCPDELTA1"
+
+	deltaOpCopy    byte = 'C'
+	deltaOpLiteral byte = 'L'
+	deltaOpEnd     byte = 'E'
+
+	// maxLiteral limits the size of a single literal operation.
+	maxLiteral = 1 << 16
+)
+
+type (
+	// blockSignature holds the checksums of one block of the remote file.
+	blockSignature struct {
+		Index  int
+		Size   int
+		Weak   uint32
+		Strong string
+	}
+
+	// signature describes the remote copy of a file.
+	signature struct {
+		BlockSize int
+		Blocks    []blockSignature
+		weak      map[uint32][]blockSignature
+	}
+)
+
+// deltaBlockSize picks a block size close to the square root of the file size.
+func deltaBlockSize(size int64) int {
+	bs := 1 << 10
+	for int64(bs)*int64(bs) < size && bs < 1<<17 {
+		bs <<= 1
+	}
+	return bs
+}
+
+// weakSum is the rsync rolling checksum of a block.
+func weakSum(block []byte) (a, b uint32) {
+	n := uint32(len(block))
+	for i, c := range block {
+		a += uint32(c)
+		b += (n - uint32(i)) * uint32(c)
+	}
+	return a & 0xffff, b & 0xffff
+}
+
+func strongSum(block []byte) string {
+	sum := sha256.Sum256(block)
+	return hex.EncodeToString(sum[:16])
+}
+
+// writeSignature prints the block signatures of r, one block per line.
+func writeSignature(w io.Writer, r io.Reader, blockSize int) error {
+	if blockSize <= 0 {
+		return errInvalidSignature
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, blockSize)
+
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			a, b := weakSum(buf[:n])
+			fmt.Fprintf(bw, "%08x %s %d\n", b<<16|a, strongSum(buf[:n]), n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return bw.Flush()
+}
+
+// parseSignature reads the output of writeSignature.
+func parseSignature(out string) (*signature, error) {
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	bs, err := strconv.Atoi(strings.TrimSpace(lines[0]))
+	if err != nil || bs <= 0 {
+		return nil, errInvalidSignature
+	}
+
+	sig := &signature{
+		BlockSize: bs,
+		weak:      map[uint32][]blockSignature{},
+	}
+	for i, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, errInvalidSignature
+		}
+		weak, err := strconv.ParseUint(fields[0], 16, 32)
+		if err != nil {
+			return nil, errInvalidSignature
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, errInvalidSignature
+		}
+		block := blockSignature{
+			Index:  i,
+			Size:   size,
+			Weak:   uint32(weak),
+			Strong: fields[1],
+		}
+		sig.Blocks = append(sig.Blocks, block)
+		sig.weak[block.Weak] = append(sig.weak[block.Weak], block)
+	}
+
+	return sig, nil
+}
+
+// match returns the index of the remote block equal to data.
+func (s *signature) match(weak uint32, data []byte) (int, bool) {
+	candidates, ok := s.weak[weak]
+	if !ok {
+		return 0, false
+	}
+	strong := strongSum(data)
+	for _, block := range candidates {
+		if block.Size == len(data) && block.Strong == strong {
+			return block.Index, true
+		}
+	}
+	return 0, false
+}
+
+// deltaWriter encodes the delta operations.
+type deltaWriter struct {
+	w       *bufio.Writer
+	literal []byte
+	sent    int64
+}
+
+func (d *deltaWriter) flush() error {
+	if len(d.literal) == 0 {
+		return nil
+	}
+	header := make([]byte, 5)
+	header[0] = deltaOpLiteral
+	binary.BigEndian.PutUint32(header[1:], uint32(len(d.literal)))
+	if _, err := d.w.Write(header); err != nil {
+		return err
+	}
+	if _, err := d.w.Write(d.literal); err != nil {
+		return err
+	}
+	d.sent += int64(len(d.literal))
+	d.literal = d.literal[:0]
+	return nil
+}
+
+func (d *deltaWriter) addLiteral(data ...byte) error {
+	d.literal = append(d.literal, data...)
+	if len(d.literal) >= maxLiteral {
+		return d.flush()
+	}
+	return nil
+}
+
+func (d *deltaWriter) addCopy(index int) error {
+	if err := d.flush(); err != nil {
+		return err
+	}
+	op := make([]byte, 5)
+	op[0] = deltaOpCopy
+	binary.BigEndian.PutUint32(op[1:], uint32(index))
+	_, err := d.w.Write(op)
+	return err
+}
+
+// writeDelta compares r with the remote signature and writes the operations
+// rebuilding r from the remote copy. It returns the number of literal bytes.
+func writeDelta(w io.Writer, r io.Reader, sig *signature) (int64, error) {
+	d := &deltaWriter{w: bufio.NewWriter(w)}
+	if _, err := d.w.WriteString(deltaMagic); err != nil {
+		return 0, err
+	}
+
+	hash := sha256.New()
+	br := bufio.NewReaderSize(io.TeeReader(r, hash), 1<<16)
+	bs := sig.BlockSize
+
+	// window holds the bytes of the current block candidate
+	window := make([]byte, 0, bs)
+	fill := func() error {
+		for len(window) < bs {
+			c, err := br.ReadByte()
+			if err != nil {
+				return err
+			}
+			window = append(window, c)
+		}
+		return nil
+	}
+
+	eof := false
+	if err := fill(); err == io.EOF {
+		eof = true
+	} else if err != nil {
+		return 0, err
+	}
+
+	a, b := weakSum(window)
+	for !eof {
+		if index, ok := sig.match(b<<16|a, window); ok {
+			if err := d.addCopy(index); err != nil {
+				return 0, err
+			}
+			window = window[:0]
+			if err := fill(); err == io.EOF {
+				eof = true
+			} else if err != nil {
+				return 0, err
+			}
+			a, b = weakSum(window)
+			continue
+		}
+
+		c, err := br.ReadByte()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		// roll the checksum one byte forward
+		out := uint32(window[0])
+		if err := d.addLiteral(window[0]); err != nil {
+			return 0, err
+		}
+		a = (a - out + uint32(c)) & 0xffff
+		b = (b - uint32(bs)*out + a) & 0xffff
+		window = append(window[1:], c)
+	}
+
+	// the remaining bytes may still match the short last block
+	if len(window) > 0 {
+		wa, wb := weakSum(window)
+		if index, ok := sig.match(wb<<16|wa, window); ok {
+			if err := d.addCopy(index); err != nil {
+				return 0, err
+			}
+		} else if err := d.addLiteral(window...); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.flush(); err != nil {
+		return 0, err
+	}
+
+	if err := d.w.WriteByte(deltaOpEnd); err != nil {
+		return 0, err
+	}
+	if _, err := d.w.Write(hash.Sum(nil)); err != nil {
+		return 0, err
+	}
+	return d.sent, d.w.Flush()
+}
+
+// applyDelta rebuilds the new file into w from the basis and the delta.
+func applyDelta(w io.Writer, basis io.ReaderAt, delta io.Reader, blockSize int) error {
+	br := bufio.NewReader(delta)
+	magic := make([]byte, len(deltaMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != deltaMagic {
+		return errInvalidDelta
+	}
+
+	hash := sha256.New()
+	out := io.MultiWriter(w, hash)
+	block := make([]byte, blockSize)
+	header := make([]byte, 4)
+	for {
+		op, err := br.ReadByte()
+		if err != nil {
+			return errInvalidDelta
+		}
+
+		switch op {
+		case deltaOpCopy:
+			if _, err := io.ReadFull(br, header); err != nil {
+				return errInvalidDelta
+			}
+			offset := int64(binary.BigEndian.Uint32(header)) * int64(blockSize)
+			n, err := basis.ReadAt(block, offset)
+			if err != nil && err != io.EOF {
+				return err
+			}
+			if _, err := out.Write(block[:n]); err != nil {
+				return err
+			}
+		case deltaOpLiteral:
+			if _, err := io.ReadFull(br, header); err != nil {
+				return errInvalidDelta
+			}
+			if _, err := io.CopyN(out, br, int64(binary.BigEndian.Uint32(header))); err != nil {
+				return errInvalidDelta
+			}
+		case deltaOpEnd:
+			sum := make([]byte, sha256.Size)
+			if _, err := io.ReadFull(br, sum); err != nil {
+				return errInvalidDelta
+			}
+			if !bytes.Equal(sum, hash.Sum(nil)) {
+				return errDeltaChecksum
+			}
+			return nil
+		default:
+			return errInvalidDelta
+		}
+	}
+}
+
+// patchFile applies the delta to file, replacing it atomically and keeping its mode.
+func patchFile(file string, delta io.Reader, blockSize int) error {
+	basis, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer basis.Close()
+
+	info, err := basis.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := file + ".drone-scp-tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+
+	if err := applyDelta(out, basis, delta, blockSize); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, file)
+}
+
+// deltaSignature prints the block signatures of a file on the remote host.
+func deltaSignature(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return errInvalidDeltaArgs
+	}
+	blockSize, err := strconv.Atoi(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return writeSignature(os.Stdout, f, blockSize)
+}
+
+// deltaPatch applies a delta read from a file or stdin ("-") on the remote host.
+func deltaPatch(c *cli.Context) error {
+	if c.NArg() != 3 {
+		return errInvalidDeltaArgs
+	}
+	blockSize, err := strconv.Atoi(c.Args().Get(2))
+	if err != nil {
+		return err
+	}
+
+	delta := os.Stdin
+	if name := c.Args().Get(1); name != "-" {
+		if delta, err = os.Open(name); err != nil {
+			return err
+		}
+		defer delta.Close()
+	}
+
+	return patchFile(c.Args().Get(0), delta, blockSize)
+}
+
+// helperCache remembers the delta helper installed on every host.
+type helperCache struct {
+	sync.Mutex
+	exe   string
+	hash  string
+	paths map[string]string
+}
+
+// unamePlatform converts the output of "uname -sm" into GOOS and GOARCH.
+func unamePlatform(out string) (string, string) {
+	fields := strings.Fields(strings.ToLower(out))
+	if len(fields) != 2 {
+		return "", ""
+	}
+
+	arch := fields[1]
+	switch {
+	case arch == "x86_64" || arch == "amd64":
+		arch = "amd64"
+	case arch == "aarch64" || arch == "arm64":
+		arch = "arm64"
+	case strings.HasPrefix(arch, "armv"):
+		arch = "arm"
+	case arch == "i386" || arch == "i686":
+		arch = "386"
+	}
+
+	return fields[0], arch
+}
+
+// deltaHelper uploads the running executable into a temporary folder, only
+// accessible by the deploy user, and returns its path, or an empty string when
+// the platform differs. The folder is removed by removeDeltaHelper.
+func (p *Plugin) deltaHelper(ssh *easyssh.MakeConfig, cache *helperCache) string {
+	cache.Lock()
+	defer cache.Unlock()
+
+	if helper, ok := cache.paths[ssh.Server]; ok {
+		return helper
+	}
+	if cache.paths == nil {
+		cache.paths = map[string]string{}
+	}
+	cache.paths[ssh.Server] = ""
+
+	outStr, _, _, err := p.run(ssh, "uname -sm")
+	if err != nil {
+		p.log(ssh.Server, "delta transfer disabled:", err)
+		return ""
+	}
+	if goos, goarch := unamePlatform(outStr); goos != runtime.GOOS || goarch != runtime.GOARCH {
+		p.log(ssh.Server, "delta transfer disabled: remote platform is", strings.TrimSpace(outStr))
+		return ""
+	}
+
+	if cache.exe == "" {
+		exe, err := os.Executable()
+		if err == nil {
+			cache.hash, err = fileHash(exe)
+		}
+		if err != nil {
+			p.log(ssh.Server, "delta transfer disabled:", err)
+			return ""
+		}
+		cache.exe = exe
+	}
+
+	// a fresh folder per run, so no file planted by another user is executed
+	outStr, errStr, _, err := p.run(ssh, mktempcmd("unix", p.Config.TarTmpPath))
+	dir := strings.TrimSpace(outStr)
+	if err != nil || dir == "" {
+		p.log(ssh.Server, "delta transfer disabled:", err, errStr)
+		return ""
+	}
+
+	helper := dir + "/drone-scp"
+	p.log(ssh.Server, "upload delta helper", helper)
+	err = p.retry(ssh.Server, "upload delta helper", func() error {
+		return ssh.Scp(cache.exe, helper)
+	})
+	if err == nil {
+		_, _, _, err = p.run(ssh, "chmod 700 "+helper)
+	}
+	if err == nil {
+		err = p.verifyChecksum("unix", ssh, helper, cache.hash)
+	}
+	if err != nil {
+		p.log(ssh.Server, "delta transfer disabled:", err)
+		if _, _, _, err := p.run(ssh, rmcmd("unix", dir)); err != nil {
+			p.log(ssh.Server, "remove delta helper failed:", err)
+		}
+		return ""
+	}
+
+	cache.paths[ssh.Server] = helper
+	return helper
+}
+
+// removeDeltaHelper removes the folder of the delta helper uploaded to the host.
+func (p *Plugin) removeDeltaHelper(ssh *easyssh.MakeConfig, cache *helperCache) {
+	cache.Lock()
+	defer cache.Unlock()
+
+	helper := cache.paths[ssh.Server]
+	if helper == "" {
+		return
+	}
+	delete(cache.paths, ssh.Server)
+	if _, _, _, err := p.run(ssh, rmcmd("unix", path.Dir(helper))); err != nil {
+		p.log(ssh.Server, "remove delta helper failed:", err)
+	}
+}
+
+// deltaFiles sends the large changed files which already exist in the target
+// folder as a delta and returns the files which still need to be archived.
+func (p *Plugin) deltaFiles(ssh *easyssh.MakeConfig, target string, state *incrementalState, previous manifest, files []localFile) []localFile {
+	existing := map[string]manifestFile{}
+	for _, f := range previous.Files {
+		existing[f.Path] = f
+	}
+
+	var helper string
+	rest := make([]localFile, 0, len(files))
+	for i, f := range files {
+		old, ok := existing[f.Name]
+		if !ok || !f.Mode.IsRegular() || !old.Mode.IsRegular() || f.Size < p.Config.DeltaMinSize {
+			rest = append(rest, f)
+			continue
+		}
+
+		if helper == "" {
+			if helper = p.deltaHelper(ssh, &state.helpers); helper == "" {
+				return append(rest, files[i:]...)
+			}
+		}
+
+		if err := p.deltaTransfer(ssh, helper, target, f, deltaBlockSize(old.Size)); err != nil {
+			p.log(ssh.Server, "delta transfer failed for", f.Name+":", err)
+			rest = append(rest, f)
+		}
+	}
+
+	return rest
+}
+
+// deltaTransfer updates a single remote file with the delta against its current content.
+func (p *Plugin) deltaTransfer(ssh *easyssh.MakeConfig, helper, target string, f localFile, blockSize int) error {
+	remote := target + "/" + shellQuote(f.Name)
+
+	session, client, err := ssh.Connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stderr = &stderr
+	out, err := session.Output(fmt.Sprintf("%s delta signature %s %d", helper, remote, blockSize))
+	if err != nil {
+		return fmt.Errorf("%w %s", err, stderr.String())
+	}
+
+	sig, err := parseSignature(string(out))
+	if err != nil {
+		return err
+	}
+
+	src, err := os.Open(f.Path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	patch, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer patch.Close()
+
+	pr, pw := io.Pipe()
+	patch.Stdin = p.throttle(pr)
+	stderr.Reset()
+	patch.Stderr = &stderr
+
+	var sent int64
+	go func() {
+		var err error
+		sent, err = writeDelta(pw, src, sig)
+		pw.CloseWithError(err)
+	}()
+
+	cmd := fmt.Sprintf("%s delta patch %s - %d && chmod %o %s", helper, remote, sig.BlockSize, f.Mode.Perm(), remote)
+	if p.Config.Debug {
+		fmt.Println("$", cmd)
+	}
+	if err := patch.Run(cmd); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("%w %s", err, stderr.String())
+	}
+
+	p.sent.add(net.JoinHostPort(ssh.Server, ssh.Port), sent)
+	p.log(ssh.Server, fmt.Sprintf("delta %s: sent %d of %d bytes", f.Name, sent, f.Size))
+	return nil
+}
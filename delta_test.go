@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func roundTrip(t *testing.T, basis, target []byte, blockSize int) int64 {
+	var sig bytes.Buffer
+	assert.NoError(t, writeSignature(&sig, bytes.NewReader(basis), blockSize))
+
+	s, err := parseSignature(sig.String())
+	assert.NoError(t, err)
+	assert.Equal(t, blockSize, s.BlockSize)
+
+	var delta bytes.Buffer
+	sent, err := writeDelta(&delta, bytes.NewReader(target), s)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, applyDelta(&out, bytes.NewReader(basis), &delta, blockSize))
+	assert.Equal(t, target, out.Bytes())
+
+	return sent
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	basis := make([]byte, 100*1024+17)
+	rnd.Read(basis)
+
+	// identical file only copies blocks
+	assert.Equal(t, int64(0), roundTrip(t, basis, basis, 1024))
+
+	// a few bytes inserted in the middle shift every following block
+	target := append([]byte{}, basis[:50000]...)
+	target = append(target, []byte("inserted bytes")...)
+	target = append(target, basis[50000:]...)
+	sent := roundTrip(t, basis, target, 1024)
+	assert.Less(t, sent, int64(3*1024))
+
+	// changed bytes at the beginning and appended data
+	target = append([]byte("header"), basis[6:]...)
+	target = append(target, []byte("trailer")...)
+	sent = roundTrip(t, basis, target, 1024)
+	assert.Less(t, sent, int64(3*1024))
+
+	// empty basis and empty target
+	assert.Equal(t, int64(len(basis)), roundTrip(t, nil, basis, 1024))
+	assert.Equal(t, int64(0), roundTrip(t, basis, nil, 1024))
+}
+
+func TestApplyDeltaChecksum(t *testing.T) {
+	basis := bytes.Repeat([]byte("0123456789"), 300)
+
+	var sig bytes.Buffer
+	assert.NoError(t, writeSignature(&sig, bytes.NewReader(basis), 1024))
+	s, err := parseSignature(sig.String())
+	assert.NoError(t, err)
+
+	var delta bytes.Buffer
+	_, err = writeDelta(&delta, bytes.NewReader(basis), s)
+	assert.NoError(t, err)
+
+	// a different basis produces a different file
+	other := bytes.Repeat([]byte("9876543210"), 300)
+	var out bytes.Buffer
+	assert.Equal(t, errDeltaChecksum, applyDelta(&out, bytes.NewReader(other), &delta, 1024))
+
+	assert.Equal(t, errInvalidDelta, applyDelta(&out, bytes.NewReader(basis), bytes.NewReader([]byte("foo")), 1024))
+}
+
+func TestDeltaBlockSize(t *testing.T) {
+	assert.Equal(t, 1024, deltaBlockSize(0))
+	assert.Equal(t, 1<<16, deltaBlockSize(4<<30))
+	assert.Equal(t, 1<<17, deltaBlockSize(1<<40))
+}
+
+func TestPatchFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "data.bin")
+	basis := bytes.Repeat([]byte("drone-scp "), 1000)
+	assert.NoError(t, os.WriteFile(file, basis, 0o600))
+
+	var sig bytes.Buffer
+	assert.NoError(t, writeSignature(&sig, bytes.NewReader(basis), 1024))
+	s, err := parseSignature(sig.String())
+	assert.NoError(t, err)
+
+	target := append([]byte("new "), basis...)
+	var delta bytes.Buffer
+	_, err = writeDelta(&delta, bytes.NewReader(target), s)
+	assert.NoError(t, err)
+
+	assert.NoError(t, patchFile(file, &delta, 1024))
+	got, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	assert.Equal(t, target, got)
+
+	info, err := os.Stat(file)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
+
+func TestUnamePlatform(t *testing.T) {
+	goos, goarch := unamePlatform("Linux x86_64\n")
+	assert.Equal(t, "linux", goos)
+	assert.Equal(t, "amd64", goarch)
+
+	goos, goarch = unamePlatform("Darwin arm64")
+	assert.Equal(t, "darwin", goos)
+	assert.Equal(t, "arm64", goarch)
+
+	_, goarch = unamePlatform("Linux armv7l")
+	assert.Equal(t, "arm", goarch)
+
+	goos, goarch = unamePlatform("")
+	assert.Empty(t, goos)
+	assert.Empty(t, goarch)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	errDiffFound         = errors.New("targets differ from the source")
+	errInvalidCompare    = errors.New("compare supports size, mtime and hash")
+	errDiffUnsupportedOS = errors.New("diff is not supported on windows host")
+)
+
+// diffResult lists the differences between the source and a target folder.
+type diffResult struct {
+	Added    []string
+	Modified []string
+	Deleted  []string
+}
+
+func (d diffResult) empty() bool {
+	return len(d.Added) == 0 && len(d.Modified) == 0 && len(d.Deleted) == 0
+}
+
+// compareFiles compares the local entries, named relative to the target, with
+// the remote listing by size and modification time. Regular files found on
+// both sides are returned as well, to be compared by hash.
+func compareFiles(local []localFile, remote map[string]remoteFile, compare []string, excludes []string) (diffResult, []localFile, error) {
+	by := map[string]bool{}
+	for _, v := range trimValues(compare) {
+		if v != "size" && v != "mtime" && v != "hash" {
+			return diffResult{}, nil, errInvalidCompare
+		}
+		by[v] = true
+	}
+
+	plan := syncPlan(local, remote, excludes)
+	result := diffResult{Added: plan.Added, Deleted: plan.Removed}
+
+	var both []localFile
+	for _, f := range local {
+		r, ok := remote[f.Name]
+		if !ok || f.IsDir() {
+			continue
+		}
+		switch {
+		case by["size"] && r.Size != f.Size,
+			by["mtime"] && r.ModTime != f.ModTime.Unix():
+			result.Modified = append(result.Modified, f.Name)
+		case by["hash"] && f.Mode.IsRegular():
+			both = append(both, f)
+		}
+	}
+	return result, both, nil
+}
+
+// Diff lists the files added, modified and deleted between the source and
+// every target on every host. It returns errDiffFound when they differ.
+func (p *Plugin) Diff(compare []string) error {
+	hosts := trimValues(p.Config.Host)
+	if len(hosts) == 0 {
+		return errMissingHost
+	}
+	if len(p.Config.Source) == 0 || len(p.Config.Target) == 0 {
+		return errMissingSourceOrTarget
+	}
+
+	files, err := p.sourceList()
+	if err != nil {
+		return err
+	}
+	list, err := walkFiles(files, p.Config.TarDereference)
+	if err != nil {
+		return err
+	}
+	local := p.relativeFiles(list)
+	excludes := append([]string{manifestName, revisionName}, p.Config.SyncExclude...)
+
+	// validate the comparison before connecting
+	if _, _, err := compareFiles(nil, nil, compare, nil); err != nil {
+		return err
+	}
+
+	hashes := map[string]string{}
+	differ := false
+	for _, h := range hosts {
+		ssh := p.sshConfig(h)
+		systemType := p.systemType(ssh)
+		if systemType == "windows" {
+			return errDiffUnsupportedOS
+		}
+
+		for _, target := range p.Config.Target {
+			folder := strings.ReplaceAll(target, " ", "\\ ")
+			remote, err := p.listRemoteFiles(systemType, ssh, folder)
+			if err != nil {
+				return err
+			}
+
+			result, both, err := compareFiles(local, remote, compare, excludes)
+			if err != nil {
+				return err
+			}
+
+			if len(both) > 0 {
+				names := make([]string, 0, len(both))
+				for _, f := range both {
+					names = append(names, f.Name)
+				}
+				sums, err := p.remoteChecksums(systemType, ssh, folder, names)
+				if err != nil {
+					return err
+				}
+				for _, f := range both {
+					if _, ok := hashes[f.Name]; !ok {
+						if hashes[f.Name], err = fileHash(f.Path); err != nil {
+							return err
+						}
+					}
+					if sums[f.Name] != hashes[f.Name] {
+						result.Modified = append(result.Modified, f.Name)
+					}
+				}
+			}
+			sort.Strings(result.Modified)
+
+			for _, name := range result.Added {
+				p.log(ssh.Server, "+", remotePath(target, name))
+			}
+			for _, name := range result.Modified {
+				p.log(ssh.Server, "~", remotePath(target, name))
+			}
+			for _, name := range result.Deleted {
+				p.log(ssh.Server, "-", remotePath(target, name))
+			}
+			p.log(ssh.Server, fmt.Sprintf("diff %s: %d added, %d modified, %d deleted",
+				target, len(result.Added), len(result.Modified), len(result.Deleted)))
+
+			if !result.empty() {
+				differ = true
+			}
+		}
+	}
+
+	if differ {
+		return errDiffFound
+	}
+	return nil
+}
+
+func diffCommand(c *cli.Context) error {
+	p := newPlugin(c)
+	return p.Diff(c.StringSlice("compare"))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompareFiles(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	local := []localFile{
+		{Name: "css", Mode: os.ModeDir | 0o755},
+		{Name: "css/app.css", Size: 10, Mode: 0o644, ModTime: now},
+		{Name: "index.html", Size: 20, Mode: 0o644, ModTime: now},
+		{Name: "about.html", Size: 30, Mode: 0o644, ModTime: now},
+		{Name: "new.html", Size: 5, Mode: 0o644, ModTime: now},
+	}
+	remote := map[string]remoteFile{
+		"css":            {Name: "css", Dir: true},
+		"css/app.css":    {Name: "css/app.css", Size: 10, ModTime: now.Unix()},
+		"index.html":     {Name: "index.html", Size: 21, ModTime: now.Unix()},
+		"about.html":     {Name: "about.html", Size: 30, ModTime: now.Unix() + 60},
+		"old.html":       {Name: "old.html", Size: 1},
+		manifestName:     {Name: manifestName, Size: 1},
+		"uploads":        {Name: "uploads", Dir: true},
+		"uploads/a.png":  {Name: "uploads/a.png", Size: 1},
+		"cache":          {Name: "cache", Dir: true},
+		"cache/data.bin": {Name: "cache/data.bin", Size: 1},
+	}
+	excludes := []string{manifestName, "uploads"}
+
+	result, both, err := compareFiles(local, remote, []string{"size", "mtime"}, excludes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"new.html"}, result.Added)
+	assert.Equal(t, []string{"index.html", "about.html"}, result.Modified)
+	assert.Equal(t, []string{"cache", "old.html"}, result.Deleted)
+	assert.Empty(t, both)
+	assert.False(t, result.empty())
+
+	// only the size differs for index.html, the others are hashed
+	result, both, err = compareFiles(local, remote, []string{"size", "hash"}, excludes)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"index.html"}, result.Modified)
+	assert.Len(t, both, 2)
+	assert.Equal(t, "css/app.css", both[0].Name)
+	assert.Equal(t, "about.html", both[1].Name)
+
+	_, _, err = compareFiles(local, remote, []string{"owner"}, excludes)
+	assert.Equal(t, errInvalidCompare, err)
+
+	assert.True(t, diffResult{}.empty())
+}
+
+func TestDiffTargetFolderWithSpaces(t *testing.T) {
+	u, err := user.Lookup("drone-scp")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+
+	plugin := Plugin{
+		Config: Config{
+			Host:            []string{"localhost"},
+			Username:        "drone-scp",
+			Port:            22,
+			KeyPath:         "tests/.ssh/id_rsa",
+			Source:          []string{"tests/global/*"},
+			StripComponents: 2,
+			Target:          []string{filepath.Join(u.HomeDir, "diff 123 456")},
+			CommandTimeout:  60 * time.Second,
+			TarExec:         "tar",
+		},
+	}
+
+	assert.NoError(t, plugin.Exec())
+	assert.NoError(t, plugin.Diff([]string{"size", "hash"}))
+
+	// a changed file is reported
+	assert.NoError(t, os.WriteFile(filepath.Join(u.HomeDir, "diff 123 456", "c.txt"), []byte("changed"), 0o644))
+	assert.Equal(t, errDiffFound, plugin.Diff([]string{"size", "hash"}))
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	errDriftFound      = errors.New("targets differ from the deployed manifest")
+	errManifestMissing = errors.New("no manifest found, deploy with the manifest or incremental option first")
+)
+
+// driftError describes how a target differs from its manifest.
+type driftError struct {
+	tampered, missing, extra int
+}
+
+func (e driftError) Error() string {
+	return fmt.Sprintf("%d tampered, %d missing, %d extra files", e.tampered, e.missing, e.extra)
+}
+
+// checkManifest compares the manifest with the remote listing and the remote
+// checksums. Entries protected by the exclude patterns are never extra.
+func checkManifest(m manifest, remote map[string]remoteFile, sums map[string]string, excludes []string) (tampered, missing, extra []string) {
+	deployed := map[string]bool{}
+	for _, f := range m.Files {
+		deployed[f.Path] = true
+		r, ok := remote[f.Path]
+		switch {
+		case !ok || r.Dir:
+			missing = append(missing, f.Path)
+		case f.Mode.IsRegular() && sums[f.Path] != f.SHA256:
+			tampered = append(tampered, f.Path)
+		}
+	}
+
+	for name, r := range remote {
+		if !r.Dir && !deployed[name] && !isProtected(name, excludes) {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	return tampered, missing, extra
+}
+
+// verifyTarget checks a target folder against the manifest written at deploy time.
+func (p *Plugin) verifyTarget(systemType string, ssh *easyssh.MakeConfig, target string, tr *targetReport) error {
+	if systemType == "windows" {
+		return errVerifyFilesUnsupportedOS
+	}
+
+	m, err := p.readManifest(systemType, ssh, target)
+	if err != nil {
+		return err
+	}
+	if len(m.Files) == 0 {
+		return errManifestMissing
+	}
+
+	remote, err := p.listRemoteFiles(systemType, ssh, target)
+	if err != nil {
+		return err
+	}
+
+	var names []string
+	for _, f := range m.Files {
+		if r, ok := remote[f.Path]; ok && !r.Dir && f.Mode.IsRegular() {
+			names = append(names, f.Path)
+		}
+	}
+	sums, err := p.remoteChecksums(systemType, ssh, target, names)
+	if err != nil {
+		return err
+	}
+
+	excludes := append([]string{manifestName, revisionName}, p.Config.SyncExclude...)
+	tr.Tampered, tr.Missing, tr.Extra = checkManifest(m, remote, sums, excludes)
+	for _, name := range tr.Tampered {
+		p.log(ssh.Server, "~", remotePath(target, name))
+	}
+	for _, name := range tr.Missing {
+		p.log(ssh.Server, "-", remotePath(target, name))
+	}
+	for _, name := range tr.Extra {
+		p.log(ssh.Server, "+", remotePath(target, name))
+	}
+
+	if len(tr.Tampered)+len(tr.Missing)+len(tr.Extra) > 0 {
+		return driftError{len(tr.Tampered), len(tr.Missing), len(tr.Extra)}
+	}
+	return nil
+}
+
+// Verify checks every target on every host against its manifest and writes
+// the result to the configured reports.
+func (p *Plugin) Verify() error {
+	hosts := trimValues(p.Config.Host)
+	if len(hosts) == 0 {
+		return errMissingHost
+	}
+	if len(p.Config.Target) == 0 {
+		return errMissingSourceOrTarget
+	}
+
+	rep := p.newReport(hosts)
+	failed := false
+	for i, h := range hosts {
+		hr := rep.Hosts[i]
+		ssh := p.sshConfig(h)
+
+		hr.begin("connect")
+		systemType := p.systemType(ssh)
+		hr.OS = systemType
+
+		var hostErr error
+		for _, target := range p.Config.Target {
+			tr := hr.target(target)
+			hr.begin("verify")
+			err := p.verifyTarget(systemType, ssh, strings.ReplaceAll(target, " ", "\\ "), tr)
+			tr.finish(err)
+			if err != nil {
+				p.log(ssh.Server, fmt.Sprintf("verify %s: %v", target, err))
+				hostErr = errDriftFound
+				continue
+			}
+			p.log(ssh.Server, fmt.Sprintf("verify %s: ok", target))
+		}
+		hr.finish(hostErr)
+		failed = failed || hostErr != nil
+	}
+
+	var err error
+	if failed {
+		err = errDriftFound
+	}
+	rep.finish(p, "", nil, err)
+	if err := p.writeReports(rep); err != nil {
+		return err
+	}
+	return err
+}
+
+func verifyCommand(c *cli.Context) error {
+	p := newPlugin(c)
+	return p.Verify()
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckManifest(t *testing.T) {
+	m := manifest{
+		Files: []manifestFile{
+			{Path: "index.html", Mode: 0o644, SHA256: "aaa"},
+			{Path: "css/app.css", Mode: 0o644, SHA256: "bbb"},
+			{Path: "about.html", Mode: 0o644, SHA256: "ccc"},
+			{Path: "latest", Mode: os.ModeSymlink | 0o777, SHA256: "ddd"},
+		},
+	}
+	remote := map[string]remoteFile{
+		"index.html":    {Name: "index.html"},
+		"css":           {Name: "css", Dir: true},
+		"css/app.css":   {Name: "css/app.css"},
+		"latest":        {Name: "latest"},
+		"evil.php":      {Name: "evil.php"},
+		"uploads":       {Name: "uploads", Dir: true},
+		"uploads/a.png": {Name: "uploads/a.png"},
+		manifestName:    {Name: manifestName},
+	}
+	sums := map[string]string{
+		"index.html":  "aaa",
+		"css/app.css": "changed",
+	}
+
+	tampered, missing, extra := checkManifest(m, remote, sums, []string{manifestName, "uploads"})
+	assert.Equal(t, []string{"css/app.css"}, tampered)
+	assert.Equal(t, []string{"about.html"}, missing)
+	assert.Equal(t, []string{"evil.php"}, extra)
+
+	assert.Equal(t, "1 tampered, 1 missing, 1 extra files", driftError{1, 1, 1}.Error())
+}
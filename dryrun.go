@@ -0,0 +1,162 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+)
+
+var errDryRunFailed = errors.New("dry run found problems on at least one host")
+
+// remotePath returns the location of an entry, named relative to the target, on the remote host.
+func remotePath(target, name string) string {
+	return strings.TrimSuffix(target, "/") + "/" + name
+}
+
+// planFiles prints every local file with the path it gets below each target.
+func (p *Plugin) planFiles(files fileList) error {
+	for _, v := range files.Ignore {
+		fmt.Println("ignore", v)
+	}
+
+	list, err := walkFiles(files, p.Config.TarDereference)
+	if err != nil {
+		return err
+	}
+	list = p.relativeFiles(list)
+
+	count := 0
+	for _, f := range list {
+		if f.IsDir() {
+			continue
+		}
+		count++
+		for _, target := range p.Config.Target {
+			fmt.Printf("%s -> %s\n", f.Path, remotePath(target, f.Name))
+		}
+	}
+	fmt.Printf("%d files, %d targets\n", count, len(p.Config.Target))
+	return nil
+}
+
+// planCommands returns the remote commands Exec runs on a host.
+func (p *Plugin) planCommands(systemType string, comp compression) []string {
+	var commands []string
+	if !p.Config.Incremental {
+		commands = append(commands, "# upload the archive to "+p.DestFile)
+	}
+
+	for _, target := range p.Config.Target {
+		target = strings.ReplaceAll(target, " ", "\\ ")
+		if p.Config.Remove {
+			commands = append(commands, rmcmd(systemType, target))
+		}
+		commands = append(commands, mkdircmd(systemType, target))
+		if p.Config.Sync {
+			commands = append(commands, listcmd(systemType, target))
+		}
+		if p.Config.Incremental {
+			commands = append(commands,
+				catcmd(systemType, target+"/"+manifestName),
+				"# upload and extract the changed files, remove the deleted files")
+		} else {
+			commands = append(commands, strings.Join(p.untarArgs(comp, p.DestFile, target), " "))
+		}
+		if p.Config.Sync {
+			commands = append(commands, "# remove the files missing from the source")
+		}
+		if p.Config.ChangedSinceDelete && p.changes != nil {
+			commands = append(commands, "# remove the files deleted since "+p.changes.Revision)
+		}
+		if p.revision != "" {
+			commands = append(commands, "# record the deployed revision "+p.revision)
+		}
+	}
+
+	if !p.Config.Incremental {
+		commands = append(commands, rmcmd(systemType, p.DestFile))
+	}
+	return commands
+}
+
+// checkHost connects to the host and checks the folders written by Exec.
+func (p *Plugin) checkHost(ssh *easyssh.MakeConfig) (string, bool) {
+	host := ssh.Server
+	session, client, err := ssh.Connect()
+	if err != nil {
+		p.log(host, "✗ connect:", err)
+		return "", false
+	}
+	session.Close()
+	client.Close()
+	p.log(host, "✓ connect and authenticate as", ssh.User)
+
+	systemType := p.systemType(ssh)
+	p.log(host, "remote server os type is "+systemType)
+	return systemType, p.checkWritable(systemType, ssh)
+}
+
+// checkWritable checks write access to the temporary folder and every target.
+func (p *Plugin) checkWritable(systemType string, ssh *easyssh.MakeConfig) bool {
+	host := ssh.Server
+	ok := true
+	folders := []string{strings.TrimSuffix(p.Config.TarTmpPath, "/")}
+	if folders[0] == "" {
+		folders[0] = "."
+	}
+	for _, target := range p.Config.Target {
+		folders = append(folders, strings.ReplaceAll(target, " ", "\\ "))
+	}
+	for _, folder := range folders {
+		cmd := writablecmd(systemType, folder)
+		if cmd == "" {
+			p.log(host, "? write access to", folder, "is not checked on", systemType)
+			continue
+		}
+		if _, _, _, err := p.run(ssh, cmd); err != nil {
+			p.log(host, "✗ write access to", folder)
+			ok = false
+			continue
+		}
+		p.log(host, "✓ write access to", folder)
+	}
+	return ok
+}
+
+// dryRun prints what Exec would do without creating, uploading or removing anything.
+func (p *Plugin) dryRun(hosts []string, comp compression, files fileList) error {
+	fmt.Println("drone-scp version: " + Version)
+	fmt.Println("dry run, nothing is uploaded or removed")
+
+	if err := p.planFiles(files); err != nil {
+		return err
+	}
+	if files.selected() {
+		// the files selected by the ignore rules and the filter are passed as a list
+		fmt.Println("$", p.Config.TarExec, "--null --no-recursion -T <file list>", strings.Join(p.tarArgs(comp, "<archive>", fileList{}), " "))
+	} else {
+		fmt.Println("$", p.Config.TarExec, strings.Join(p.tarArgs(comp, "<archive>", files), " "))
+	}
+
+	failed := false
+	for _, h := range hosts {
+		ssh := p.sshConfig(h)
+		systemType, ok := p.checkHost(ssh)
+		if !ok {
+			failed = true
+			if systemType == "" {
+				continue
+			}
+		}
+		for _, cmd := range p.planCommands(systemType, p.hostCompression(systemType, ssh, comp)) {
+			p.log(ssh.Server, "$", cmd)
+		}
+	}
+
+	if failed {
+		return errDryRunFailed
+	}
+	return nil
+}
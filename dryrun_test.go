@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRemotePath(t *testing.T) {
+	assert.Equal(t, "/var/www/css/app.css", remotePath("/var/www/", "css/app.css"))
+	assert.Equal(t, "dist/index.html", remotePath("dist", "index.html"))
+}
+
+func TestPlanCommands(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Target:          []string{"/var/www/my app"},
+			TarExec:         "tar",
+			StripComponents: 1,
+			Remove:          true,
+		},
+		DestFile: "/tmp/a.tar.gz",
+	}
+
+	assert.Equal(t, []string{
+		"# upload the archive to /tmp/a.tar.gz",
+		"rm -rf /var/www/my\\ app",
+		"mkdir -p /var/www/my\\ app",
+		"tar -zxf /tmp/a.tar.gz --strip-components 1 -C /var/www/my\\ app",
+		"rm -rf /tmp/a.tar.gz",
+	}, p.planCommands("unix", compressions["gzip"]))
+
+	p.Config.Remove = false
+	p.Config.Incremental = true
+	p.Config.Sync = true
+	assert.Equal(t, []string{
+		"mkdir -p /var/www/my\\ app",
+		"find /var/www/my\\ app -mindepth 1 -printf '%y\\t%s\\t%T@\\t%P\\n'",
+		"if [ -f /var/www/my\\ app/.drone-scp-manifest.json ]; then cat /var/www/my\\ app/.drone-scp-manifest.json; fi",
+		"# upload and extract the changed files, remove the deleted files",
+		"# remove the files missing from the source",
+	}, p.planCommands("unix", compressions["gzip"]))
+}
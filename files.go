@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// localFile describes an entry which will be written into the archive.
+type localFile struct {
+	// Path is the location of the file on the local disk.
+	Path string
+	// Name is the archive member name, as written by tar.
+	Name    string
+	Size    int64
+	Mode    os.FileMode
+	ModTime time.Time
+}
+
+// IsDir reports whether the entry is a directory.
+func (f localFile) IsDir() bool {
+	return f.Mode.IsDir()
+}
+
+// archiveName returns the member name tar uses for a local path.
+// Like GNU tar, leading slashes are removed but a leading "./" is kept.
+func archiveName(path string) string {
+	name := filepath.ToSlash(path)
+	for strings.HasPrefix(name, "/") {
+		name = name[1:]
+	}
+	return name
+}
+
+// stripComponents removes n leading path elements from an archive member
+// name, returning an empty string when nothing is left (tar skips those).
+func stripComponents(name string, n int) string {
+	name = strings.TrimSuffix(name, "/")
+	if n <= 0 {
+		return name
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) <= n {
+		return ""
+	}
+	return strings.Join(parts[n:], "/")
+}
+
+// isIgnored reports whether path equals one of the ignore entries or lives below one.
+func isIgnored(path string, ignores []string) bool {
+	path = filepath.Clean(path)
+	for _, v := range ignores {
+		v = filepath.Clean(v)
+		if path == v || strings.HasPrefix(path, v+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// walkFiles expands the source list the same way tar does: directories are
+// walked recursively and ignored entries and the entries excluded by the
+// ignore rules or the filter are skipped.
+func walkFiles(files fileList, dereference bool) ([]localFile, error) {
+	var list []localFile
+	seen := map[string]bool{}
+
+	add := func(path, name string, info os.FileInfo) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		if dereference && info.Mode()&os.ModeSymlink != 0 {
+			if target, err := os.Stat(path); err == nil {
+				info = target
+			}
+		}
+		list = append(list, localFile{
+			Path:    path,
+			Name:    name,
+			Size:    info.Size(),
+			Mode:    info.Mode(),
+			ModTime: info.ModTime(),
+		})
+	}
+
+	for _, source := range files.Source {
+		if isIgnored(source, files.Ignore) {
+			continue
+		}
+		if info, err := os.Lstat(source); err == nil && files.Rules.ignored(ignoreName(source), info.IsDir()) {
+			continue
+		}
+
+		root := archiveName(source)
+		err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != source && (isIgnored(path, files.Ignore) || files.Rules.ignored(ignoreName(path), info.IsDir())) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if reason, descend := files.Filter.check(path, info); reason != "" {
+				files.Filter.log(path, reason)
+				if info.IsDir() && !descend {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// keep the source prefix as given so tar writes the same member name
+			name := root
+			if rel, _ := filepath.Rel(source, path); rel != "." {
+				path = strings.TrimSuffix(source, string(filepath.Separator)) + string(filepath.Separator) + rel
+				name = strings.TrimSuffix(root, "/") + "/" + filepath.ToSlash(rel)
+			}
+			add(path, name, info)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files.Filter.removeEmptyDirs(list), nil
+}
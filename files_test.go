@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveName(t *testing.T) {
+	assert.Equal(t, "tests/a.txt", archiveName("tests/a.txt"))
+	assert.Equal(t, "./tests/a.txt", archiveName("./tests/a.txt"))
+	assert.Equal(t, "tmp/tests/a.txt", archiveName("/tmp/tests/a.txt"))
+}
+
+func TestStripComponents(t *testing.T) {
+	assert.Equal(t, "a/b", stripComponents("a/b", 0))
+	assert.Equal(t, "b", stripComponents("a/b", 1))
+	assert.Equal(t, "", stripComponents("a/b", 2))
+	assert.Equal(t, "b/c", stripComponents("a/b/c/", 1))
+}
+
+func TestWalkFiles(t *testing.T) {
+	files, err := walkFiles(globList([]string{"tests/*.txt", "!tests/b.txt"}), false)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, "tests/a.txt", files[0].Name)
+
+	files, err = walkFiles(globList([]string{"./tests/global", "tests/a.txt"}), false)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "./tests/global")
+	assert.Contains(t, names, "tests/a.txt")
+	for _, name := range names[:len(names)-1] {
+		assert.Regexp(t, `^\./tests/global`, name)
+	}
+}
+
+func TestWalkFilesKeepPrefix(t *testing.T) {
+	files, err := walkFiles(globList([]string{"./tests/global"}), false)
+	assert.NoError(t, err)
+	for _, f := range files {
+		assert.Equal(t, f.Name, filepath.ToSlash(f.Path))
+	}
+}
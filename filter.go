@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errInvalidSize          = errors.New("invalid file size, expected a value like 10MB")
+	errInvalidModifiedSince = errors.New("modified since expects a duration like 24h, a date like 2024-01-31 or a git revision")
+)
+
+// fileFilter selects the local entries by size, age and type.
+type fileFilter struct {
+	MinSize       int64
+	MaxSize       int64
+	ModifiedSince time.Time
+	NoSymlinks    bool
+	NoEmptyDirs   bool
+	NoHidden      bool
+	RegularOnly   bool
+	// Changed lists the only files copied, named relative to the working
+	// directory, nil copies all files.
+	Changed      map[string]bool
+	ChangedSince string
+
+	// debug prints every decision once.
+	debug  bool
+	mu     sync.Mutex
+	logged map[string]bool
+}
+
+// parseModifiedSince accepts a duration counted back from now, a date or a
+// time in RFC 3339 format, or a git revision whose commit time is used.
+func parseModifiedSince(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+
+	out, err := exec.Command("git", "show", "-s", "--format=%ct", value, "--").Output()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", errInvalidModifiedSince, value)
+	}
+	unix, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %q", errInvalidModifiedSince, value)
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// fileFilter returns the filter built from the settings, nil when no filter is set.
+func (p *Plugin) fileFilter() (*fileFilter, error) {
+	c := p.Config
+	f := &fileFilter{
+		NoSymlinks:  c.NoSymlinks,
+		NoEmptyDirs: c.NoEmptyDirs,
+		NoHidden:    c.NoHidden,
+		RegularOnly: c.RegularOnly,
+		debug:       c.Debug,
+	}
+	if p.changes != nil {
+		f.Changed, f.ChangedSince = p.changes.Changed, p.changes.Revision
+	}
+
+	for _, v := range []struct {
+		value string
+		size  *int64
+	}{{c.MinSize, &f.MinSize}, {c.MaxSize, &f.MaxSize}} {
+		if v.value = strings.TrimSpace(v.value); v.value == "" {
+			continue
+		}
+		size, ok := parseSize(v.value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", errInvalidSize, v.value)
+		}
+		*v.size = size
+	}
+
+	if since := strings.TrimSpace(c.ModifiedSince); since != "" {
+		t, err := parseModifiedSince(since, time.Now())
+		if err != nil {
+			return nil, err
+		}
+		f.ModifiedSince = t
+	}
+
+	if f.MinSize == 0 && f.MaxSize == 0 && f.ModifiedSince.IsZero() && f.Changed == nil &&
+		!f.NoSymlinks && !f.NoEmptyDirs && !f.NoHidden && !f.RegularOnly {
+		return nil, nil
+	}
+	return f, nil
+}
+
+// check returns why the entry is left out, or an empty string to keep it.
+// descend reports whether the content of a folder is walked nevertheless.
+func (f *fileFilter) check(path string, info os.FileInfo) (reason string, descend bool) {
+	if f == nil {
+		return "", true
+	}
+
+	mode := info.Mode()
+	name := filepath.Base(path)
+	switch {
+	case f.NoHidden && strings.HasPrefix(name, ".") && name != "." && name != "..":
+		return "hidden", false
+	case f.NoSymlinks && mode&os.ModeSymlink != 0:
+		return "symbolic link", false
+	case f.RegularOnly && mode.IsDir():
+		return "folder", true
+	case f.RegularOnly && !mode.IsRegular():
+		return "not a regular file", false
+	}
+
+	if f.Changed != nil && !mode.IsDir() && !f.Changed[ignoreName(path)] {
+		return "unchanged since " + f.ChangedSince, false
+	}
+
+	if !mode.IsRegular() {
+		return "", true
+	}
+	switch {
+	case f.MinSize > 0 && info.Size() < f.MinSize:
+		return fmt.Sprintf("smaller than %s", formatBytes(f.MinSize)), false
+	case f.MaxSize > 0 && info.Size() > f.MaxSize:
+		return fmt.Sprintf("larger than %s", formatBytes(f.MaxSize)), false
+	case !f.ModifiedSince.IsZero() && info.ModTime().Before(f.ModifiedSince):
+		return "modified before " + f.ModifiedSince.Format(time.RFC3339), false
+	}
+	return "", true
+}
+
+// log prints a decision in debug mode, once per entry as the files are walked
+// several times.
+func (f *fileFilter) log(path, reason string) {
+	if f == nil || !f.debug {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.logged[path] {
+		return
+	}
+	if f.logged == nil {
+		f.logged = map[string]bool{}
+	}
+	f.logged[path] = true
+	fmt.Printf("filter: skip %s (%s)\n", path, reason)
+}
+
+// removeEmptyDirs drops the folders without any file below them, which
+// includes the folders without changed files.
+func (f *fileFilter) removeEmptyDirs(list []localFile) []localFile {
+	if f == nil || !f.NoEmptyDirs && f.Changed == nil {
+		return list
+	}
+
+	used := map[string]bool{}
+	for _, file := range list {
+		if file.IsDir() {
+			continue
+		}
+		for dir := filepath.Dir(file.Path); !used[dir]; dir = filepath.Dir(dir) {
+			used[dir] = true
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	kept := list[:0]
+	for _, file := range list {
+		if file.IsDir() && !used[filepath.Clean(file.Path)] {
+			f.log(file.Path, "empty folder")
+			continue
+		}
+		kept = append(kept, file)
+	}
+	return kept
+}
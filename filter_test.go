@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseModifiedSince(t *testing.T) {
+	now := time.Date(2024, 1, 31, 12, 0, 0, 0, time.UTC)
+
+	since, err := parseModifiedSince("24h", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(-24*time.Hour), since)
+
+	since, err = parseModifiedSince("2024-01-02T03:04:05Z", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), since.UTC())
+
+	since, err = parseModifiedSince("2024-01-02", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local), since)
+
+	_, err = parseModifiedSince("no-such-revision", now)
+	assert.ErrorIs(t, err, errInvalidModifiedSince)
+
+	out, err := exec.Command("git", "show", "-s", "--format=%ct", "HEAD").Output()
+	if err != nil {
+		t.Skip("not a git checkout")
+	}
+	since, err = parseModifiedSince("HEAD", now)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(out)), strconv.FormatInt(since.Unix(), 10))
+}
+
+func TestPluginFileFilter(t *testing.T) {
+	p := Plugin{}
+	f, err := p.fileFilter()
+	assert.NoError(t, err)
+	assert.Nil(t, f)
+
+	p.Config.MaxSize = "10MB"
+	p.Config.NoHidden = true
+	f, err = p.fileFilter()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(10*1000*1000), f.MaxSize)
+	assert.True(t, f.NoHidden)
+
+	p.Config.MinSize = "ten"
+	_, err = p.fileFilter()
+	assert.ErrorIs(t, err, errInvalidSize)
+}
+
+func TestWalkFilesFilter(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, size int) {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o600))
+	}
+	write("small.txt", 10)
+	write("large.bin", 4096)
+	write(".env", 10)
+	write(".cache/data", 10)
+	write("css/app.css", 100)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "empty", "inner"), 0o755))
+	assert.NoError(t, os.Symlink("small.txt", filepath.Join(dir, "link.txt")))
+	old := time.Now().Add(-48 * time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, "css", "app.css"), old, old))
+
+	names := func(filter *fileFilter) []string {
+		list, err := walkFiles(fileList{Source: []string{dir}, Filter: filter}, false)
+		assert.NoError(t, err)
+		var names []string
+		for _, f := range list {
+			rel, _ := filepath.Rel(dir, f.Path)
+			names = append(names, filepath.ToSlash(rel))
+		}
+		return names
+	}
+
+	assert.ElementsMatch(t, []string{".", ".cache", ".cache/data", ".env", "css", "css/app.css", "empty", "empty/inner", "large.bin", "link.txt", "small.txt"}, names(nil))
+	assert.ElementsMatch(t, []string{".", "css", "css/app.css", "empty", "empty/inner", "large.bin", "link.txt", "small.txt"}, names(&fileFilter{NoHidden: true}))
+	assert.ElementsMatch(t, []string{".", ".cache", ".cache/data", ".env", "css", "css/app.css", "large.bin", "link.txt", "small.txt"}, names(&fileFilter{NoEmptyDirs: true}))
+	assert.ElementsMatch(t, []string{".", ".cache", ".cache/data", ".env", "css", "css/app.css", "empty", "empty/inner", "large.bin", "small.txt"}, names(&fileFilter{NoSymlinks: true}))
+	assert.ElementsMatch(t, []string{".cache/data", ".env", "css/app.css", "large.bin", "small.txt"}, names(&fileFilter{RegularOnly: true}))
+	assert.ElementsMatch(t, []string{".", ".cache", "css", "css/app.css", "empty", "empty/inner", "large.bin", "link.txt"}, names(&fileFilter{MinSize: 100}))
+	assert.ElementsMatch(t, []string{".", ".cache", ".cache/data", ".env", "css", "empty", "empty/inner", "link.txt", "small.txt"}, names(&fileFilter{MaxSize: 50, ModifiedSince: time.Now().Add(-time.Hour)}))
+}
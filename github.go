@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// markdownEscape keeps a value from breaking the summary table.
+func markdownEscape(value string) string {
+	value = strings.ReplaceAll(value, "|", "\\|")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// summary renders the report as a markdown table for the GitHub step summary.
+func (r *report) summary(targets []string) string {
+	var b strings.Builder
+	b.WriteString("### drone-scp\n\n")
+	fmt.Fprintf(&b, "%d files, %s archive, %s sent in %.1fs\n\n",
+		r.Files, formatBytes(r.Archive.Size), formatBytes(r.Bytes), r.Duration)
+	b.WriteString("| Host | Target | Status | Duration |\n")
+	b.WriteString("| --- | --- | --- | --- |\n")
+
+	for _, c := range r.junit(targets).Suites[0].Cases {
+		status := "✅ success"
+		switch {
+		case c.Failure != nil:
+			status = "❌ " + markdownEscape(c.Failure.Message)
+		case c.Skipped != nil:
+			status = "⏭️ skipped"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %.1fs |\n",
+			c.ClassName, markdownEscape(c.Name), status, c.Time)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// outputs returns the step outputs of the run.
+func (r *report) outputs() map[string]string {
+	var deployed, failed []string
+	for _, h := range r.Hosts {
+		host := net.JoinHostPort(h.Host, h.Port)
+		switch h.Status {
+		case statusSuccess:
+			deployed = append(deployed, host)
+		case statusFailure:
+			failed = append(failed, host)
+		}
+	}
+
+	return map[string]string{
+		"deployed_hosts": strings.Join(deployed, ","),
+		"failed_hosts":   strings.Join(failed, ","),
+		"archive_sha256": r.Archive.SHA256,
+	}
+}
+
+func appendFile(file, content string) error {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeGitHub appends the summary and the outputs to the files provided by
+// GitHub Actions.
+func (r *report) writeGitHub(summary, output string, targets []string) error {
+	if summary != "" {
+		if err := appendFile(summary, r.summary(targets)); err != nil {
+			return err
+		}
+	}
+
+	if output != "" {
+		var b strings.Builder
+		outputs := r.outputs()
+		for _, key := range []string{"deployed_hosts", "failed_hosts", "archive_sha256"} {
+			fmt.Fprintf(&b, "%s=%s\n", key, outputs[key])
+		}
+		if err := appendFile(output, b.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitHubOutputs(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Port: 22,
+		},
+	}
+	rep := p.newReport([]string{"a.example.com", "b.example.com", "c.example.com"})
+	rep.Archive.SHA256 = "abc"
+	rep.Hosts[0].target("/var/www").finish(nil)
+	rep.Hosts[0].finish(nil)
+	rep.Hosts[1].finish(errors.New("dial tcp: connection refused"))
+
+	assert.Equal(t, map[string]string{
+		"deployed_hosts": "a.example.com:22",
+		"failed_hosts":   "b.example.com:22",
+		"archive_sha256": "abc",
+	}, rep.outputs())
+
+	dir := t.TempDir()
+	summary := filepath.Join(dir, "summary.md")
+	output := filepath.Join(dir, "output")
+	assert.NoError(t, os.WriteFile(output, []byte("previous=1\n"), 0o644))
+	assert.NoError(t, rep.writeGitHub(summary, output, []string{"/var/www"}))
+
+	data, err := os.ReadFile(output)
+	assert.NoError(t, err)
+	assert.Equal(t, "previous=1\ndeployed_hosts=a.example.com:22\nfailed_hosts=b.example.com:22\narchive_sha256=abc\n", string(data))
+
+	data, err = os.ReadFile(summary)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "| a.example.com:22 | /var/www | ✅ success |")
+	assert.Contains(t, string(data), "| b.example.com:22 | /var/www | ❌ /var/www was not deployed: dial tcp: connection refused |")
+	assert.Contains(t, string(data), "| c.example.com:22 | /var/www | ⏭️ skipped |")
+}
+
+func TestMarkdownEscape(t *testing.T) {
+	assert.Equal(t, "a \\| b c", markdownEscape("a | b\nc"))
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// defaultIgnoreFile is read from the working directory when it exists.
+const defaultIgnoreFile = ".scpignore"
+
+type (
+	// ignoreRule is a single gitignore pattern.
+	ignoreRule struct {
+		pattern string
+		// negate re-includes the entries matched by the pattern.
+		negate bool
+		// dirOnly matches folders only, the pattern ended with a slash.
+		dirOnly bool
+	}
+
+	// ignoreRules follow the gitignore semantics, the last matching rule wins.
+	ignoreRules []ignoreRule
+)
+
+// parseIgnoreRule parses a line of a gitignore file, returning false for
+// blank lines and comments.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	// trailing spaces are ignored unless they are escaped
+	line = strings.TrimRight(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\\ ") {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+
+	var r ignoreRule
+	switch {
+	case strings.HasPrefix(line, "!"):
+		r.negate = true
+		line = line[1:]
+	case strings.HasPrefix(line, "\\#"), strings.HasPrefix(line, "\\!"):
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return ignoreRule{}, false
+	}
+
+	// a pattern without a slash matches at any level, otherwise it is
+	// relative to the working directory
+	if strings.Contains(line, "/") {
+		line = strings.TrimPrefix(line, "/")
+	} else {
+		line = "**/" + line
+	}
+	r.pattern = line
+	return r, true
+}
+
+// parseIgnoreRules parses gitignore patterns, skipping blank lines and comments.
+func parseIgnoreRules(lines []string) ignoreRules {
+	var rules ignoreRules
+	for _, line := range lines {
+		if r, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// readIgnoreFile returns the patterns of a gitignore file.
+func readIgnoreFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// match reports whether the last rule matching name excludes it.
+func (rules ignoreRules) match(name string, isDir bool) bool {
+	ignored := false
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if ok, _ := doublestar.Match(r.pattern, name); ok {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// ignored reports whether the entry at the given slash separated path,
+// relative to the working directory, is excluded. Like git, an entry can't be
+// re-included when one of its parent folders is excluded.
+func (rules ignoreRules) ignored(name string, isDir bool) bool {
+	if len(rules) == 0 {
+		return false
+	}
+	name = strings.TrimPrefix(path.Clean(name), "./")
+	parts := strings.Split(name, "/")
+	for i := 1; i < len(parts); i++ {
+		if rules.match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return rules.match(name, isDir)
+}
+
+// ignoreName returns the path matched by the ignore rules, relative to the
+// working directory when the entry lives below it.
+func ignoreName(file string) string {
+	if filepath.IsAbs(file) {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, file); err == nil && !strings.HasPrefix(rel, "..") {
+				return filepath.ToSlash(rel)
+			}
+		}
+	}
+	return archiveName(filepath.Clean(file))
+}
+
+// ignoreRules returns the patterns of the ignore files followed by the exclude
+// patterns, which take precedence. The .scpignore file of the working
+// directory is read when no ignore file is given.
+func (p *Plugin) ignoreRules() (ignoreRules, error) {
+	var lines []string
+	files := trimValues(p.Config.IgnoreFile)
+	if len(files) == 0 {
+		if _, err := os.Stat(defaultIgnoreFile); err == nil {
+			files = []string{defaultIgnoreFile}
+		}
+	}
+	for _, file := range files {
+		patterns, err := readIgnoreFile(file)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, patterns...)
+	}
+	return parseIgnoreRules(append(lines, p.Config.Exclude...)), nil
+}
+
+// sourceList expands the source patterns and attaches the ignore rules and the filter.
+func (p *Plugin) sourceList() (fileList, error) {
+	files := globList(trimValues(p.Config.Source))
+	rules, err := p.ignoreRules()
+	if err != nil {
+		return fileList{}, err
+	}
+	files.Rules = rules
+	if files.Filter, err = p.fileFilter(); err != nil {
+		return fileList{}, err
+	}
+	return files, nil
+}
+
+// selected reports whether the ignore rules or the filter select the entries,
+// which tar then reads from a list.
+func (files fileList) selected() bool {
+	return len(files.Rules) > 0 || files.Filter != nil
+}
+
+// writeFileList writes the local paths, separated by NUL, into a temporary
+// file read by tar --null -T.
+func writeFileList(files []localFile) (string, error) {
+	list, err := os.CreateTemp("", "drone-scp-*.list")
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if _, err := list.WriteString(f.Path + "\x00"); err != nil {
+			list.Close()
+			os.Remove(list.Name())
+			return "", err
+		}
+	}
+	if err := list.Close(); err != nil {
+		os.Remove(list.Name())
+		return "", err
+	}
+	return list.Name(), nil
+}
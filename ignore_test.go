@@ -0,0 +1,143 @@
+package main
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIgnoreRule(t *testing.T) {
+	for line, want := range map[string]ignoreRule{
+		"*.log":          {pattern: "**/*.log"},
+		"/build":         {pattern: "build"},
+		"dist/**/*.map":  {pattern: "dist/**/*.map"},
+		"node_modules/":  {pattern: "**/node_modules", dirOnly: true},
+		"!keep.log":      {pattern: "**/keep.log", negate: true},
+		"\\#notes.txt":   {pattern: "**/#notes.txt"},
+		"\\!important":   {pattern: "**/!important"},
+		"trailing.txt  ": {pattern: "**/trailing.txt"},
+	} {
+		r, ok := parseIgnoreRule(line)
+		assert.True(t, ok, line)
+		assert.Equal(t, want, r, line)
+	}
+
+	for _, line := range []string{"", "   ", "# comment", "/"} {
+		_, ok := parseIgnoreRule(line)
+		assert.False(t, ok, line)
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	rules := parseIgnoreRules([]string{
+		"*.log",
+		"!important.log",
+		"/build/",
+		"docs/**/*.md",
+		"cache/",
+		"!cache/keep.txt",
+	})
+
+	assert.True(t, rules.ignored("app.log", false))
+	assert.True(t, rules.ignored("logs/app.log", false))
+	assert.False(t, rules.ignored("logs/important.log", false))
+	assert.True(t, rules.ignored("build", true))
+	assert.False(t, rules.ignored("build", false))
+	assert.True(t, rules.ignored("build/app.js", false))
+	assert.False(t, rules.ignored("src/build/app.js", false))
+	assert.True(t, rules.ignored("docs/a/b/readme.md", false))
+	assert.True(t, rules.ignored("./docs/readme.md", false))
+	assert.False(t, rules.ignored("src/docs/readme.md", false))
+	// an entry below an excluded folder can't be re-included
+	assert.True(t, rules.ignored("cache/keep.txt", false))
+
+	var empty ignoreRules
+	assert.False(t, empty.ignored("app.log", false))
+}
+
+func TestPluginIgnoreRules(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, ".gitignore")
+	assert.NoError(t, os.WriteFile(file, []byte("# build output\n*.map\n"), 0o600))
+
+	p := Plugin{Config: Config{IgnoreFile: []string{file}, Exclude: []string{"!vendor.map"}}}
+	rules, err := p.ignoreRules()
+	assert.NoError(t, err)
+	assert.Equal(t, ignoreRules{{pattern: "**/*.map"}, {pattern: "**/vendor.map", negate: true}}, rules)
+
+	p.Config.IgnoreFile = []string{filepath.Join(dir, "missing")}
+	_, err = p.ignoreRules()
+	assert.Error(t, err)
+}
+
+func TestWalkFilesIgnoreRules(t *testing.T) {
+	files := globList([]string{"tests"})
+	files.Rules = parseIgnoreRules([]string{".ssh/", "*.txt", "!b.txt"})
+	list, err := walkFiles(files, false)
+	assert.NoError(t, err)
+
+	var names []string
+	for _, f := range list {
+		names = append(names, f.Name)
+	}
+	assert.Contains(t, names, "tests/b.txt")
+	assert.Contains(t, names, "tests/global")
+	assert.NotContains(t, names, "tests/a.txt")
+	assert.NotContains(t, names, "tests/.ssh")
+	assert.NotContains(t, names, "tests/.ssh/id_rsa")
+
+	files = globList([]string{"tests/a.txt", "tests/b.txt"})
+	files.Rules = parseIgnoreRules([]string{"a.txt"})
+	list, err = walkFiles(files, false)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, "tests/b.txt", list[0].Name)
+}
+
+func TestGlobListDoubleStar(t *testing.T) {
+	assert.Equal(t, []string{"tests/a.txt", "tests/b.txt"}, globList([]string{"**/tests/*.txt"}).Source)
+	assert.Contains(t, globList([]string{"tests/**/id_rsa*"}).Source, "tests/.ssh/id_rsa.pub")
+
+	files := globList([]string{"tests/**/*.txt", "!tests/**/b.txt"})
+	assert.Contains(t, files.Source, "tests/global/c.txt")
+	assert.Equal(t, []string{"tests/b.txt"}, files.Ignore)
+
+	assert.Equal(t, []string{"tests"}, globList([]string{"tests/**"}).Source)
+	assert.Equal(t, []string{"tests/global"}, globList([]string{"tests/global/**", "!tests/global/c.txt"}).Source)
+}
+
+func TestDoubleStarArchiveMembers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "web", "a", "b"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "web", "index.html"), []byte("index"), 0o600))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "web", "a", "b", "c.txt"), []byte("c"), 0o600))
+
+	p := Plugin{Config: Config{TarExec: "tar", Compression: "none"}}
+	c, err := p.compression()
+	assert.NoError(t, err)
+	src := filepath.Join(t.TempDir(), "archive"+c.Ext)
+	assert.NoError(t, p.createArchive(c, src, nil, globList([]string{filepath.Join(dir, "web", "**")})))
+
+	f, err := os.Open(src)
+	assert.NoError(t, err)
+	defer f.Close()
+
+	// every file is stored once, whatever its depth
+	members := map[string]int{}
+	r := tar.NewReader(f)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		members[filepath.Base(h.Name)]++
+	}
+	assert.Equal(t, 1, members["index.html"])
+	assert.Equal(t, 1, members["c.txt"])
+	assert.Equal(t, 1, members["b"])
+}
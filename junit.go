@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Skipped  int          `xml:"skipped,attr"`
+		Time     float64      `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+
+	junitSuite struct {
+		Name      string      `xml:"name,attr"`
+		Tests     int         `xml:"tests,attr"`
+		Failures  int         `xml:"failures,attr"`
+		Skipped   int         `xml:"skipped,attr"`
+		Time      float64     `xml:"time,attr"`
+		Timestamp string      `xml:"timestamp,attr"`
+		Cases     []junitCase `xml:"testcase"`
+	}
+
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		ClassName string        `xml:"classname,attr"`
+		Time      float64       `xml:"time,attr"`
+		Failure   *junitFailure `xml:"failure,omitempty"`
+		Skipped   *junitSkipped `xml:"skipped,omitempty"`
+		SystemErr string        `xml:"system-err,omitempty"`
+	}
+
+	junitFailure struct {
+		Message string `xml:"message,attr"`
+		Text    string `xml:",chardata"`
+	}
+
+	junitSkipped struct {
+		Message string `xml:"message,attr,omitempty"`
+	}
+)
+
+// junit converts the report to a test suite with a testcase for every host
+// and target. Targets which were never reached fail with the error of the
+// host, or are skipped when the host did not run at all.
+func (r *report) junit(targets []string) junitSuites {
+	suite := junitSuite{
+		Name:      "drone-scp",
+		Time:      r.Duration,
+		Timestamp: r.StartedAt.Format("2006-01-02T15:04:05"),
+	}
+
+	for _, h := range r.Hosts {
+		results := map[string]*targetReport{}
+		for _, t := range h.Targets {
+			results[t.Target] = t
+		}
+
+		for _, target := range h.expected(targets) {
+			c := junitCase{
+				Name:      target,
+				ClassName: net.JoinHostPort(h.Host, h.Port),
+			}
+
+			t, ok := results[target]
+			switch {
+			case ok:
+				c.Time = t.Duration
+				if t.Status == statusFailure {
+					c.Failure = &junitFailure{Message: t.Error, Text: t.Stderr}
+				}
+				c.SystemErr = t.Stderr
+			case h.Status == statusFailure:
+				c.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s was not deployed: %s", target, h.Error),
+					Text:    h.Error,
+				}
+			default:
+				c.Skipped = &junitSkipped{Message: "host was not deployed"}
+			}
+
+			if c.Failure != nil {
+				suite.Failures++
+			}
+			if c.Skipped != nil {
+				suite.Skipped++
+			}
+			suite.Tests++
+			suite.Cases = append(suite.Cases, c)
+		}
+	}
+
+	return junitSuites{
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+}
+
+func (r *report) writeJUnit(file string, targets []string) error {
+	data, err := xml.MarshalIndent(r.junit(targets), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.Write(data)
+	b.WriteString("\n")
+	return os.WriteFile(file, []byte(b.String()), 0o644)
+}
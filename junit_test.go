@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJUnit(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Port: 22,
+		},
+	}
+	rep := p.newReport([]string{"a.example.com", "b.example.com", "c.example.com"})
+
+	a := rep.Hosts[0]
+	a.target("/var/www").finish(nil)
+	tr := a.target("/var/backup")
+	tr.Stderr = "mkdir: permission denied"
+	a.finish(errors.New("Process exited with status 1"))
+
+	rep.Hosts[1].finish(errors.New("dial tcp: connection refused"))
+
+	suites := rep.junit([]string{"/var/www", "/var/backup"})
+	assert.Equal(t, 6, suites.Tests)
+	assert.Equal(t, 3, suites.Failures)
+	assert.Equal(t, 2, suites.Skipped)
+
+	cases := suites.Suites[0].Cases
+	assert.Equal(t, "/var/www", cases[0].Name)
+	assert.Equal(t, "a.example.com:22", cases[0].ClassName)
+	assert.Nil(t, cases[0].Failure)
+	assert.Equal(t, "Process exited with status 1", cases[1].Failure.Message)
+	assert.Equal(t, "mkdir: permission denied", cases[1].Failure.Text)
+	assert.Equal(t, "/var/www was not deployed: dial tcp: connection refused", cases[2].Failure.Message)
+	assert.NotNil(t, cases[4].Skipped)
+
+	file := filepath.Join(t.TempDir(), "junit.xml")
+	assert.NoError(t, rep.writeJUnit(file, []string{"/var/www", "/var/backup"}))
+	data, err := os.ReadFile(file)
+	assert.NoError(t, err)
+
+	var result junitSuites
+	assert.NoError(t, xml.Unmarshal(data, &result))
+	assert.Equal(t, 6, result.Tests)
+	assert.Len(t, result.Suites[0].Cases, 6)
+}
@@ -23,6 +23,7 @@ func main() {
 		_ = godotenv.Load(filename)
 	}
 
+	// the runner may also provide DRONE_CARD_PATH this way
 	if _, err := os.Stat("/run/drone/env"); err == nil {
 		_ = godotenv.Overload("/run/drone/env")
 	}
@@ -40,7 +41,93 @@ func main() {
 	}
 	app.Action = run
 	app.Version = Version
-	app.Flags = []cli.Flag{
+	app.Commands = []*cli.Command{
+		{
+			Name:   "delta",
+			Usage:  "Helper executed on the remote host for delta transfers",
+			Hidden: true,
+			Subcommands: []*cli.Command{
+				{
+					Name:      "signature",
+					Usage:     "Print the block signatures of a file",
+					ArgsUsage: "<file> <block size>",
+					Action:    deltaSignature,
+				},
+				{
+					Name:      "patch",
+					Usage:     "Apply a delta to a file",
+					ArgsUsage: "<file> <delta|-> <block size>",
+					Action:    deltaPatch,
+				},
+			},
+		},
+		{
+			Name:  "diff",
+			Usage: "List the files added, modified and deleted between the source and the targets",
+			Flags: append(flags(), &cli.StringSliceFlag{
+				Name:    "compare",
+				Usage:   "Compare the files by size, mtime and hash",
+				EnvVars: []string{"PLUGIN_COMPARE", "SCP_COMPARE", "INPUT_COMPARE"},
+				Value:   cli.NewStringSlice("size", "hash"),
+			}),
+			Action: diffCommand,
+		},
+		{
+			Name:   "verify",
+			Usage:  "Check the files of every target against the manifest written at deploy time",
+			Flags:  flags(),
+			Action: verifyCommand,
+		},
+		{
+			Name:   "ping",
+			Usage:  "Check the connection, credentials, remote tar and write access of every host",
+			Flags:  flags(),
+			Action: pingCommand,
+		},
+	}
+	app.Flags = flags()
+
+	// Override a template
+	cli.AppHelpTemplate = `
+________                                         ____________________________
+\______ \_______  ____   ____   ____            /   _____/\_   ___ \______   \
+ |    |  \_  __ \/  _ \ /    \_/ __ \   ______  \_____  \ /    \  \/|     ___/
+ |    |   \  | \(  <_> )   |  \  ___/  /_____/  /        \\     \___|    |
+/_______  /__|   \____/|___|  /\___  >         /_______  / \______  /____|
+        \/                  \/     \/                  \/         \/
+                                                            version: {{.Version}}
+NAME:
+   {{.Name}} - {{.Usage}}
+
+USAGE:
+   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}}{{if .Commands}} command [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}
+   {{if len .Authors}}
+AUTHOR:
+   {{range .Authors}}{{ . }}{{end}}
+   {{end}}{{if .Commands}}
+COMMANDS:
+{{range .Commands}}{{if not .HideHelp}}   {{join .Names ", "}}{{ "\t"}}{{.Usage}}{{ "\n" }}{{end}}{{end}}{{end}}{{if .VisibleFlags}}
+GLOBAL OPTIONS:
+   {{range .VisibleFlags}}{{.}}
+   {{end}}{{end}}{{if .Copyright }}
+COPYRIGHT:
+   {{.Copyright}}
+   {{end}}{{if .Version}}
+VERSION:
+   {{.Version}}
+   {{end}}
+REPOSITORY:
+    Github: https://github.com/appleboy/drone-scp
+`
+
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// flags returns the settings shared by the transfer and the commands using the same hosts.
+func flags() []cli.Flag {
+	return []cli.Flag{
 		&cli.StringSliceFlag{
 			Name:     "host",
 			Aliases:  []string{"H"},
@@ -129,12 +216,103 @@ func main() {
 			Usage:   "Local files/directories to copy",
 			EnvVars: []string{"PLUGIN_SOURCE", "SCP_SOURCE", "INPUT_SOURCE"},
 		},
+		&cli.StringSliceFlag{
+			Name:    "exclude",
+			Usage:   "Gitignore patterns excluding local files, e.g. '**/*.map' or '!keep.map'",
+			EnvVars: []string{"PLUGIN_EXCLUDE", "SCP_EXCLUDE", "INPUT_EXCLUDE"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "ignore-file",
+			Usage:   "Files with gitignore patterns excluding local files, e.g. .gitignore (default: .scpignore if it exists)",
+			EnvVars: []string{"PLUGIN_IGNORE_FILE", "SCP_IGNORE_FILE", "INPUT_IGNORE_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "min-size",
+			Usage:   "Skip local files smaller than the given size, e.g. 1KB",
+			EnvVars: []string{"PLUGIN_MIN_SIZE", "SCP_MIN_SIZE", "INPUT_MIN_SIZE"},
+		},
+		&cli.StringFlag{
+			Name:    "max-size",
+			Usage:   "Skip local files larger than the given size, e.g. 100MB",
+			EnvVars: []string{"PLUGIN_MAX_SIZE", "SCP_MAX_SIZE", "INPUT_MAX_SIZE"},
+		},
+		&cli.StringFlag{
+			Name:    "modified-since",
+			Usage:   "Skip local files modified before a duration ago (24h), a date (2024-01-31) or the commit time of a git revision",
+			EnvVars: []string{"PLUGIN_MODIFIED_SINCE", "SCP_MODIFIED_SINCE", "INPUT_MODIFIED_SINCE"},
+		},
+		&cli.BoolFlag{
+			Name:    "no-symlinks",
+			Usage:   "Skip symbolic links",
+			EnvVars: []string{"PLUGIN_NO_SYMLINKS", "SCP_NO_SYMLINKS", "INPUT_NO_SYMLINKS"},
+		},
+		&cli.BoolFlag{
+			Name:    "no-empty-dirs",
+			Usage:   "Skip folders without any file below them",
+			EnvVars: []string{"PLUGIN_NO_EMPTY_DIRS", "SCP_NO_EMPTY_DIRS", "INPUT_NO_EMPTY_DIRS"},
+		},
+		&cli.BoolFlag{
+			Name:    "no-hidden",
+			Usage:   "Skip hidden files and folders, named with a leading dot",
+			EnvVars: []string{"PLUGIN_NO_HIDDEN", "SCP_NO_HIDDEN", "INPUT_NO_HIDDEN"},
+		},
+		&cli.BoolFlag{
+			Name:    "regular-only",
+			Usage:   "Copy regular files only, folders are created for the files they hold",
+			EnvVars: []string{"PLUGIN_REGULAR_ONLY", "SCP_REGULAR_ONLY", "INPUT_REGULAR_ONLY"},
+		},
+		&cli.StringFlag{
+			Name:    "changed-since",
+			Usage:   "Copy only the files changed in git between a revision and HEAD, auto uses the previous commit given by the CI",
+			EnvVars: []string{"PLUGIN_CHANGED_SINCE", "SCP_CHANGED_SINCE", "INPUT_CHANGED_SINCE"},
+		},
+		&cli.BoolFlag{
+			Name:    "changed-since.delete",
+			Usage:   "Remove the files deleted in git since the revision from the targets",
+			EnvVars: []string{"PLUGIN_CHANGED_SINCE_DELETE", "SCP_CHANGED_SINCE_DELETE", "INPUT_CHANGED_SINCE_DELETE"},
+		},
 		&cli.BoolFlag{
 			Name:    "rm",
 			Aliases: []string{"r"},
 			Usage:   "Delete destination folder before copying",
 			EnvVars: []string{"PLUGIN_RM", "SCP_RM", "INPUT_RM"},
 		},
+		&cli.BoolFlag{
+			Name:    "sync",
+			Usage:   "Delete files in the target folder which no longer exist in the source",
+			EnvVars: []string{"PLUGIN_SYNC", "SCP_SYNC", "INPUT_SYNC"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "sync.exclude",
+			Usage:   "Patterns of target files protected from deletion in sync mode",
+			EnvVars: []string{"PLUGIN_SYNC_EXCLUDE", "SCP_SYNC_EXCLUDE", "INPUT_SYNC_EXCLUDE"},
+		},
+		&cli.BoolFlag{
+			Name:    "incremental",
+			Usage:   "Only upload new or changed files based on the manifest stored in the target folder",
+			EnvVars: []string{"PLUGIN_INCREMENTAL", "SCP_INCREMENTAL", "INPUT_INCREMENTAL"},
+		},
+		&cli.BoolFlag{
+			Name:    "delta",
+			Usage:   "Send only the changed blocks of large files in incremental mode",
+			EnvVars: []string{"PLUGIN_DELTA", "SCP_DELTA", "INPUT_DELTA"},
+		},
+		&cli.Int64Flag{
+			Name:    "delta.min-size",
+			Usage:   "Minimum file size in bytes for delta transfer",
+			EnvVars: []string{"PLUGIN_DELTA_MIN_SIZE", "SCP_DELTA_MIN_SIZE", "INPUT_DELTA_MIN_SIZE"},
+			Value:   1 << 20,
+		},
+		&cli.StringFlag{
+			Name:    "relay",
+			Usage:   "Upload the archive once to the relay (proxy or first host) which distributes it to the other hosts",
+			EnvVars: []string{"PLUGIN_RELAY", "SCP_RELAY", "INPUT_RELAY"},
+		},
+		&cli.StringFlag{
+			Name:    "relay.key-path",
+			Usage:   "Path of the private key on the relay host used to reach the other hosts",
+			EnvVars: []string{"PLUGIN_RELAY_KEY_PATH", "SCP_RELAY_KEY_PATH", "INPUT_RELAY_KEY_PATH"},
+		},
 		// Proxy settings remain the same as they are already clear
 		&cli.StringFlag{
 			Name:    "proxy.host",
@@ -215,6 +393,142 @@ func main() {
 			Usage:   "Temporary directory for tar files on remote host",
 			EnvVars: []string{"PLUGIN_TAR_TMP_PATH", "SSH_TAR_TMP_PATH", "INPUT_TAR_TMP_PATH"},
 		},
+		&cli.StringFlag{
+			Name:    "compression",
+			Usage:   "Archive compression (gzip, zstd, xz, bzip2, none)",
+			EnvVars: []string{"PLUGIN_COMPRESSION", "SCP_COMPRESSION", "INPUT_COMPRESSION"},
+			Value:   "gzip",
+		},
+		&cli.IntFlag{
+			Name:    "compression.level",
+			Usage:   "Compression level, default is the compressor's default",
+			EnvVars: []string{"PLUGIN_COMPRESSION_LEVEL", "SCP_COMPRESSION_LEVEL", "INPUT_COMPRESSION_LEVEL"},
+		},
+		&cli.IntFlag{
+			Name:    "compression.workers",
+			Usage:   "Number of workers compressing gzip or zstd archives in parallel",
+			EnvVars: []string{"PLUGIN_COMPRESSION_WORKERS", "SCP_COMPRESSION_WORKERS", "INPUT_COMPRESSION_WORKERS"},
+			Value:   1,
+		},
+		&cli.BoolFlag{
+			Name:    "verify.checksum",
+			Usage:   "Verify the SHA-256 checksum of the uploaded archive before extracting it",
+			EnvVars: []string{"PLUGIN_VERIFY_CHECKSUM", "SCP_VERIFY_CHECKSUM", "INPUT_VERIFY_CHECKSUM"},
+		},
+		&cli.BoolFlag{
+			Name:    "verify.files",
+			Usage:   "Verify the SHA-256 checksum of every extracted file",
+			EnvVars: []string{"PLUGIN_VERIFY_FILES", "SCP_VERIFY_FILES", "INPUT_VERIFY_FILES"},
+		},
+		&cli.BoolFlag{
+			Name:    "resume",
+			Usage:   "Upload the archive in chunks and resume an interrupted upload",
+			EnvVars: []string{"PLUGIN_RESUME", "SCP_RESUME", "INPUT_RESUME"},
+		},
+		&cli.Int64Flag{
+			Name:    "chunk-size",
+			Usage:   "Chunk size in bytes of resumable uploads",
+			EnvVars: []string{"PLUGIN_CHUNK_SIZE", "SCP_CHUNK_SIZE", "INPUT_CHUNK_SIZE"},
+			Value:   defaultChunkSize,
+		},
+		&cli.IntFlag{
+			Name:    "retries",
+			Usage:   "Number of retries of failed connections, uploads and remote commands",
+			EnvVars: []string{"PLUGIN_RETRIES", "SCP_RETRIES", "INPUT_RETRIES"},
+		},
+		&cli.DurationFlag{
+			Name:    "retry.backoff",
+			Usage:   "Delay before the first retry, doubled on every attempt (default: 1s)",
+			EnvVars: []string{"PLUGIN_RETRY_BACKOFF", "SCP_RETRY_BACKOFF", "INPUT_RETRY_BACKOFF"},
+			Value:   time.Second,
+		},
+		&cli.DurationFlag{
+			Name:    "retry.max-backoff",
+			Usage:   "Maximum delay between two retries, 0 for no limit (default: 30s)",
+			EnvVars: []string{"PLUGIN_RETRY_MAX_BACKOFF", "SCP_RETRY_MAX_BACKOFF", "INPUT_RETRY_MAX_BACKOFF"},
+			Value:   30 * time.Second,
+		},
+		&cli.StringSliceFlag{
+			Name:    "retry.on",
+			Usage:   "Retryable error classes: connection, timeout, auth (default: connection,timeout)",
+			EnvVars: []string{"PLUGIN_RETRY_ON", "SCP_RETRY_ON", "INPUT_RETRY_ON"},
+		},
+		&cli.StringFlag{
+			Name:    "bandwidth-limit",
+			Usage:   "Upload bandwidth limit per host, e.g. 20MB/s",
+			EnvVars: []string{"PLUGIN_BANDWIDTH_LIMIT", "SCP_BANDWIDTH_LIMIT", "INPUT_BANDWIDTH_LIMIT"},
+		},
+		&cli.StringFlag{
+			Name:    "bandwidth-limit.total",
+			Usage:   "Upload bandwidth limit shared by all hosts, e.g. 50MB/s",
+			EnvVars: []string{"PLUGIN_BANDWIDTH_LIMIT_TOTAL", "SCP_BANDWIDTH_LIMIT_TOTAL", "INPUT_BANDWIDTH_LIMIT_TOTAL"},
+		},
+		&cli.DurationFlag{
+			Name:    "progress.interval",
+			Usage:   "Interval of progress lines, a progress bar is drawn on a terminal, 0 disables progress (default: 10s)",
+			EnvVars: []string{"PLUGIN_PROGRESS_INTERVAL", "SCP_PROGRESS_INTERVAL", "INPUT_PROGRESS_INTERVAL"},
+			Value:   10 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:    "report-file",
+			Usage:   "Write a JSON report of the run to the given file",
+			EnvVars: []string{"PLUGIN_REPORT_FILE", "SCP_REPORT_FILE", "INPUT_REPORT_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "junit-file",
+			Usage:   "Write a JUnit XML file with a testcase per host and target",
+			EnvVars: []string{"PLUGIN_JUNIT_FILE", "SCP_JUNIT_FILE", "INPUT_JUNIT_FILE"},
+		},
+		&cli.StringFlag{
+			Name:    "github.step-summary",
+			Usage:   "Append a markdown summary of the run to the GitHub Actions step summary",
+			EnvVars: []string{"GITHUB_STEP_SUMMARY"},
+		},
+		&cli.StringFlag{
+			Name:    "github.output",
+			Usage:   "Set the deployed_hosts, failed_hosts and archive_sha256 GitHub Actions outputs",
+			EnvVars: []string{"GITHUB_OUTPUT"},
+		},
+		&cli.StringFlag{
+			Name:    "card.path",
+			Usage:   "Write an adaptive card summarizing the run",
+			EnvVars: []string{"PLUGIN_CARD_PATH", "DRONE_CARD_PATH"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "webhook",
+			Usage:   "URLs receiving a POST request when the transfer starts and ends",
+			EnvVars: []string{"PLUGIN_WEBHOOK", "SCP_WEBHOOK", "INPUT_WEBHOOK"},
+		},
+		&cli.StringFlag{
+			Name:    "webhook.secret",
+			Usage:   "Secret signing the webhook payload with HMAC-SHA256",
+			EnvVars: []string{"PLUGIN_WEBHOOK_SECRET", "SCP_WEBHOOK_SECRET", "INPUT_WEBHOOK_SECRET"},
+		},
+		&cli.StringFlag{
+			Name:    "webhook.template",
+			Usage:   "Go template rendering the webhook payload, the event is sent as JSON by default",
+			EnvVars: []string{"PLUGIN_WEBHOOK_TEMPLATE", "SCP_WEBHOOK_TEMPLATE", "INPUT_WEBHOOK_TEMPLATE"},
+		},
+		&cli.BoolFlag{
+			Name:    "manifest",
+			Usage:   "Write a manifest of the deployed files into every target, checked by the verify command",
+			EnvVars: []string{"PLUGIN_MANIFEST", "SCP_MANIFEST", "INPUT_MANIFEST"},
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Usage:   "Print the files, remote paths and commands and check every host without transferring anything",
+			EnvVars: []string{"PLUGIN_DRY_RUN", "SCP_DRY_RUN", "INPUT_DRY_RUN"},
+		},
+		&cli.StringSliceFlag{
+			Name:    "mapping",
+			Usage:   "Copy sources into their own target, e.g. 'dist/* -> /var/www strip=1' or 'prod.env -> /etc/app/.env'",
+			EnvVars: []string{"PLUGIN_MAPPING", "SCP_MAPPING", "INPUT_MAPPING"},
+		},
+		&cli.StringFlag{
+			Name:    "config",
+			Usage:   "YAML file describing multiple copy jobs, run instead of the single job given by flags",
+			EnvVars: []string{"PLUGIN_CONFIG", "SCP_CONFIG", "INPUT_CONFIG"},
+		},
 		&cli.BoolFlag{
 			Name:    "debug",
 			Usage:   "Enable debug logging",
@@ -236,71 +550,99 @@ func main() {
 			EnvVars: []string{"PLUGIN_TAR_DEREFERENCE", "INPUT_TAR_DEREFERENCE"},
 		},
 	}
+}
 
-	// Override a template
-	cli.AppHelpTemplate = `
-________                                         ____________________________
-\______ \_______  ____   ____   ____            /   _____/\_   ___ \______   \
- |    |  \_  __ \/  _ \ /    \_/ __ \   ______  \_____  \ /    \  \/|     ___/
- |    |   \  | \(  <_> )   |  \  ___/  /_____/  /        \\     \___|    |
-/_______  /__|   \____/|___|  /\___  >         /_______  / \______  /____|
-        \/                  \/     \/                  \/         \/
-                                                            version: {{.Version}}
-NAME:
-   {{.Name}} - {{.Usage}}
+func run(c *cli.Context) error {
+	plugin := newPlugin(c)
 
-USAGE:
-   {{.HelpName}} {{if .VisibleFlags}}[global options]{{end}}{{if .Commands}} command [command options]{{end}} {{if .ArgsUsage}}{{.ArgsUsage}}{{else}}[arguments...]{{end}}
-   {{if len .Authors}}
-AUTHOR:
-   {{range .Authors}}{{ . }}{{end}}
-   {{end}}{{if .Commands}}
-COMMANDS:
-{{range .Commands}}{{if not .HideHelp}}   {{join .Names ", "}}{{ "\t"}}{{.Usage}}{{ "\n" }}{{end}}{{end}}{{end}}{{if .VisibleFlags}}
-GLOBAL OPTIONS:
-   {{range .VisibleFlags}}{{.}}
-   {{end}}{{end}}{{if .Copyright }}
-COPYRIGHT:
-   {{.Copyright}}
-   {{end}}{{if .Version}}
-VERSION:
-   {{.Version}}
-   {{end}}
-REPOSITORY:
-    Github: https://github.com/appleboy/drone-scp
-`
+	if file := c.String("config"); file != "" {
+		jobs, parallel, err := loadJobs(file, plugin.Config)
+		if err != nil {
+			return err
+		}
+		if plugin.Config.Debug {
+			_ = godump.Dump(jobs)
+		}
+		return runJobs(jobs, parallel)
+	}
 
-	if err := app.Run(os.Args); err != nil {
-		log.Fatal(err)
+	if plugin.Config.Debug {
+		_ = godump.Dump(plugin)
 	}
+
+	return plugin.Exec()
 }
 
-func run(c *cli.Context) error {
-	plugin := Plugin{
+// newPlugin builds the plugin from the flags.
+func newPlugin(c *cli.Context) Plugin {
+	return Plugin{
 		Config: Config{
-			Host:              c.StringSlice("host"),
-			Port:              c.Int("port"),
-			Protocol:          easyssh.Protocol(c.String("protocol")),
-			Username:          c.String("username"),
-			Password:          c.String("password"),
-			Passphrase:        c.String("ssh-passphrase"),
-			Fingerprint:       c.String("fingerprint"),
-			Timeout:           c.Duration("timeout"),
-			CommandTimeout:    c.Duration("command.timeout"),
-			Key:               c.String("ssh-key"),
-			KeyPath:           c.String("key-path"),
-			Target:            c.StringSlice("target"),
-			Source:            c.StringSlice("source"),
-			Remove:            c.Bool("rm"),
-			Debug:             c.Bool("debug"),
-			StripComponents:   c.Int("strip.components"),
-			TarExec:           c.String("tar.exec"),
-			TarTmpPath:        c.String("tar.tmp-path"),
-			Overwrite:         c.Bool("overwrite"),
-			UnlinkFirst:       c.Bool("unlink.first"),
-			Ciphers:           c.StringSlice("ciphers"),
-			UseInsecureCipher: c.Bool("useInsecureCipher"),
-			TarDereference:    c.Bool("tar.dereference"),
+			Host:               c.StringSlice("host"),
+			Port:               c.Int("port"),
+			Protocol:           easyssh.Protocol(c.String("protocol")),
+			Username:           c.String("username"),
+			Password:           c.String("password"),
+			Passphrase:         c.String("ssh-passphrase"),
+			Fingerprint:        c.String("fingerprint"),
+			Timeout:            c.Duration("timeout"),
+			CommandTimeout:     c.Duration("command.timeout"),
+			Key:                c.String("ssh-key"),
+			KeyPath:            c.String("key-path"),
+			Target:             c.StringSlice("target"),
+			Source:             c.StringSlice("source"),
+			Remove:             c.Bool("rm"),
+			Debug:              c.Bool("debug"),
+			StripComponents:    c.Int("strip.components"),
+			TarExec:            c.String("tar.exec"),
+			TarTmpPath:         c.String("tar.tmp-path"),
+			Overwrite:          c.Bool("overwrite"),
+			UnlinkFirst:        c.Bool("unlink.first"),
+			Ciphers:            c.StringSlice("ciphers"),
+			UseInsecureCipher:  c.Bool("useInsecureCipher"),
+			TarDereference:     c.Bool("tar.dereference"),
+			Sync:               c.Bool("sync"),
+			SyncExclude:        c.StringSlice("sync.exclude"),
+			Incremental:        c.Bool("incremental"),
+			Delta:              c.Bool("delta"),
+			DeltaMinSize:       c.Int64("delta.min-size"),
+			Relay:              c.String("relay"),
+			RelayKeyPath:       c.String("relay.key-path"),
+			Compression:        c.String("compression"),
+			CompressionLevel:   c.Int("compression.level"),
+			CompressionWorkers: c.Int("compression.workers"),
+			VerifyChecksum:     c.Bool("verify.checksum"),
+			VerifyFiles:        c.Bool("verify.files"),
+			Resume:             c.Bool("resume"),
+			ChunkSize:          c.Int64("chunk-size"),
+			Retries:            c.Int("retries"),
+			Backoff:            c.Duration("retry.backoff"),
+			MaxBackoff:         c.Duration("retry.max-backoff"),
+			RetryOn:            c.StringSlice("retry.on"),
+			BandwidthLimit:     c.String("bandwidth-limit"),
+			BandwidthTotal:     c.String("bandwidth-limit.total"),
+			ProgressInterval:   c.Duration("progress.interval"),
+			ReportFile:         c.String("report-file"),
+			JUnitFile:          c.String("junit-file"),
+			GitHubSummary:      c.String("github.step-summary"),
+			GitHubOutput:       c.String("github.output"),
+			CardPath:           c.String("card.path"),
+			Webhooks:           c.StringSlice("webhook"),
+			WebhookSecret:      c.String("webhook.secret"),
+			WebhookTemplate:    c.String("webhook.template"),
+			DryRun:             c.Bool("dry-run"),
+			Manifest:           c.Bool("manifest"),
+			Mapping:            c.StringSlice("mapping"),
+			Exclude:            c.StringSlice("exclude"),
+			IgnoreFile:         c.StringSlice("ignore-file"),
+			MinSize:            c.String("min-size"),
+			MaxSize:            c.String("max-size"),
+			ModifiedSince:      c.String("modified-since"),
+			NoSymlinks:         c.Bool("no-symlinks"),
+			NoEmptyDirs:        c.Bool("no-empty-dirs"),
+			NoHidden:           c.Bool("no-hidden"),
+			RegularOnly:        c.Bool("regular-only"),
+			ChangedSince:       c.String("changed-since"),
+			ChangedSinceDelete: c.Bool("changed-since.delete"),
 			Proxy: easyssh.DefaultConfig{
 				Key:               c.String("proxy.ssh-key"),
 				Passphrase:        c.String("proxy.ssh-passphrase"),
@@ -317,10 +659,4 @@ func run(c *cli.Context) error {
 			},
 		},
 	}
-
-	if plugin.Config.Debug {
-		_ = godump.Dump(plugin)
-	}
-
-	return plugin.Exec()
 }
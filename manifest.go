@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/appleboy/easyssh-proxy"
+)
+
+var errIncrementalUnsupportedOS = errors.New("incremental mode is not supported on windows host")
+
+// manifestName is the file written into every target folder in incremental mode.
+const manifestName = ".drone-scp-manifest.json"
+
+type (
+	// manifestFile records the state of a deployed file.
+	manifestFile struct {
+		Path   string      `json:"path"`
+		Size   int64       `json:"size"`
+		Mode   os.FileMode `json:"mode"`
+		SHA256 string      `json:"sha256"`
+	}
+
+	// manifest lists every file deployed into a target folder.
+	manifest struct {
+		Version string         `json:"version"`
+		Files   []manifestFile `json:"files"`
+	}
+
+	// onceCache makes sure every archive or checksum is only built once for all hosts.
+	onceCache struct {
+		sync.Mutex
+		values map[string]string
+	}
+
+	// incrementalState is shared by all hosts in incremental mode.
+	incrementalState struct {
+		archives onceCache
+		helpers  helperCache
+		local    map[string]localFile
+		manifest manifest
+	}
+)
+
+func newIncrementalState(files []localFile) (*incrementalState, error) {
+	m, err := newManifest(files)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &incrementalState{
+		local:    map[string]localFile{},
+		manifest: m,
+	}
+	for _, f := range files {
+		state.local[f.Name] = f
+	}
+	return state, nil
+}
+
+// fileHash returns the hex encoded SHA-256 of a local file.
+func fileHash(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// newManifest hashes the local files, which are already named relative to the target.
+func newManifest(files []localFile) (manifest, error) {
+	m := manifest{Version: Version}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		var sum string
+		var err error
+		if f.Mode&os.ModeSymlink != 0 {
+			var link string
+			link, err = os.Readlink(f.Path)
+			hash := sha256.Sum256([]byte(link))
+			sum = hex.EncodeToString(hash[:])
+		} else {
+			sum, err = fileHash(f.Path)
+		}
+		if err != nil {
+			return m, err
+		}
+
+		m.Files = append(m.Files, manifestFile{
+			Path:   f.Name,
+			Size:   f.Size,
+			Mode:   f.Mode,
+			SHA256: sum,
+		})
+	}
+
+	sort.Slice(m.Files, func(i, j int) bool {
+		return m.Files[i].Path < m.Files[j].Path
+	})
+	return m, nil
+}
+
+// diffManifest returns the files which are new or changed in current and the
+// files which only exist in the previous manifest.
+func diffManifest(previous, current manifest) (changed, removed []string) {
+	old := map[string]manifestFile{}
+	for _, f := range previous.Files {
+		old[f.Path] = f
+	}
+
+	for _, f := range current.Files {
+		if o, ok := old[f.Path]; !ok || o != f {
+			changed = append(changed, f.Path)
+		}
+		delete(old, f.Path)
+	}
+
+	for name := range old {
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	return changed, removed
+}
+
+func (p *Plugin) readManifest(systemType string, ssh *easyssh.MakeConfig, target string) (manifest, error) {
+	var m manifest
+	outStr, errStr, _, err := p.run(ssh, catcmd(systemType, target+"/"+manifestName))
+	if err != nil {
+		return m, err
+	}
+	if errStr != "" {
+		return m, errors.New(errStr)
+	}
+	if strings.TrimSpace(outStr) == "" {
+		return m, nil
+	}
+
+	if err := json.Unmarshal([]byte(outStr), &m); err != nil {
+		// a broken manifest results in a full upload
+		p.log(ssh.Server, "ignore invalid manifest:", err)
+		return manifest{}, nil
+	}
+	return m, nil
+}
+
+func (p *Plugin) writeManifest(ssh *easyssh.MakeConfig, target string, m manifest) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return p.retry(ssh.Server, "write manifest", func() error {
+		return ssh.WriteFile(bytes.NewReader(data), int64(len(data)), target+"/"+manifestName)
+	})
+}
+
+// get returns the value stored under key, building it on the first call.
+func (c *onceCache) get(key string, build func() (string, error)) (string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if value, ok := c.values[key]; ok {
+		return value, nil
+	}
+
+	value, err := build()
+	if err != nil {
+		return "", err
+	}
+
+	if c.values == nil {
+		c.values = map[string]string{}
+	}
+	c.values[key] = value
+	return value, nil
+}
+
+// partialArchive returns a local archive holding only the given files.
+func (p *Plugin) partialArchive(cache *onceCache, comp compression, files []localFile) (string, error) {
+	h := sha256.New()
+	fmt.Fprintln(h, comp.Name)
+	for _, f := range files {
+		fmt.Fprintln(h, f.Path)
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+
+	return cache.get(key, func() (string, error) {
+		list, err := writeFileList(files)
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(list)
+
+		src := filepath.Join(os.TempDir(), "drone-scp-"+key[:10]+comp.Ext)
+		front := []string{"--null", "--no-recursion", "-T", getRealPath(list)}
+		return src, p.createArchive(comp, src, front, fileList{})
+	})
+}
+
+// incrementalTarget uploads only the files which differ from the manifest found
+// in the target folder, removes the deleted ones and writes the new manifest.
+func (p *Plugin) incrementalTarget(systemType string, ssh *easyssh.MakeConfig, target string, comp compression, sums *onceCache, state *incrementalState) error {
+	if systemType == "windows" {
+		return errIncrementalUnsupportedOS
+	}
+
+	previous, err := p.readManifest(systemType, ssh, target)
+	if err != nil {
+		return err
+	}
+
+	changed, removed := diffManifest(previous, state.manifest)
+	if len(changed) == 0 && len(removed) == 0 {
+		p.log(ssh.Server, "target is up to date:", target)
+		return nil
+	}
+
+	files := make([]localFile, 0, len(changed))
+	for _, name := range changed {
+		files = append(files, state.local[name])
+	}
+
+	if p.Config.Delta {
+		files = p.deltaFiles(ssh, target, state, previous, files)
+	}
+
+	if len(files) > 0 {
+		src, err := p.partialArchive(&state.archives, comp, files)
+		if err != nil {
+			return err
+		}
+
+		dest := p.Config.TarTmpPath + filepath.Base(src)
+		p.log(ssh.Server, "scp changed files to server.")
+		err = p.retry(ssh.Server, "upload", func() error {
+			return p.upload(systemType, ssh, src, dest)
+		})
+		if err != nil {
+			return copyError{ssh.Server, err.Error()}
+		}
+
+		if p.Config.VerifyChecksum {
+			if err := p.verifyArchive(systemType, ssh, sums, src, dest); err != nil {
+				return err
+			}
+		}
+
+		commamd := strings.Join(p.untarArgs(comp, dest, target), " ")
+		if p.Config.Debug {
+			fmt.Println("$", commamd)
+		}
+		pr := p.startProgress(ssh.Server, "extract "+target, 0)
+		outStr, errStr, _, err := p.run(ssh, commamd)
+		pr.Stop()
+		if outStr != "" {
+			p.log(ssh.Server, "output: ", outStr)
+		}
+		if errStr != "" {
+			p.log(ssh.Server, "error: ", errStr)
+		}
+		if err != nil {
+			return err
+		}
+
+		if _, _, _, err := p.run(ssh, rmcmd(systemType, dest)); err != nil {
+			return err
+		}
+	}
+
+	if err := p.removeRemoteFiles(systemType, ssh, target, removed); err != nil {
+		return err
+	}
+
+	if err := p.writeManifest(ssh, target, state.manifest); err != nil {
+		return err
+	}
+
+	p.log(ssh.Server, fmt.Sprintf("incremental %s: %d changed, %d removed", target, len(changed), len(removed)))
+	return nil
+}
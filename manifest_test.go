@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewManifest(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			StripComponents: 1,
+		},
+	}
+	files, err := walkFiles(globList([]string{"tests/*.txt"}), false)
+	assert.NoError(t, err)
+
+	m, err := newManifest(p.relativeFiles(files))
+	assert.NoError(t, err)
+	assert.Len(t, m.Files, 2)
+	assert.Equal(t, "a.txt", m.Files[0].Path)
+	assert.Equal(t, "b.txt", m.Files[1].Path)
+
+	sum, err := fileHash("tests/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, sum, m.Files[0].SHA256)
+	assert.Len(t, sum, 64)
+}
+
+func TestDiffManifest(t *testing.T) {
+	previous := manifest{
+		Files: []manifestFile{
+			{Path: "index.html", Size: 10, Mode: 0o644, SHA256: "aaa"},
+			{Path: "app.js", Size: 20, Mode: 0o644, SHA256: "bbb"},
+			{Path: "old.css", Size: 30, Mode: 0o644, SHA256: "ccc"},
+		},
+	}
+	current := manifest{
+		Files: []manifestFile{
+			{Path: "index.html", Size: 10, Mode: 0o644, SHA256: "aaa"},
+			{Path: "app.js", Size: 20, Mode: 0o644, SHA256: "ddd"},
+			{Path: "new.css", Size: 30, Mode: 0o644, SHA256: "ccc"},
+		},
+	}
+
+	changed, removed := diffManifest(previous, current)
+	assert.Equal(t, []string{"app.js", "new.css"}, changed)
+	assert.Equal(t, []string{"old.css"}, removed)
+
+	changed, removed = diffManifest(manifest{}, current)
+	assert.Len(t, changed, 3)
+	assert.Empty(t, removed)
+
+	changed, removed = diffManifest(current, current)
+	assert.Empty(t, changed)
+	assert.Empty(t, removed)
+}
+
+func TestPartialArchive(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			TarExec: "tar",
+		},
+	}
+	cache := &onceCache{}
+	files := []localFile{{Path: "tests/b.txt", Name: "tests/b.txt"}}
+
+	src, err := p.partialArchive(cache, compressions["gzip"], files)
+	assert.NoError(t, err)
+	defer os.Remove(src)
+
+	again, err := p.partialArchive(cache, compressions["gzip"], files)
+	assert.NoError(t, err)
+	assert.Equal(t, src, again)
+
+	out, err := exec.Command("tar", "-tzf", src).Output()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tests/b.txt"}, strings.Fields(string(out)))
+}
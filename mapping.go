@@ -0,0 +1,208 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var (
+	errInvalidMapping    = errors.New("mapping must look like: source -> target [strip=N]")
+	errMappingWithSource = errors.New("mapping can't be combined with source or target")
+	errRenameWithStrip   = errors.New("mapping renaming a file can't strip components")
+	errMappingOverlap    = errors.New("mappings sharing or nesting a target can't use sync, incremental or manifest")
+)
+
+const (
+	// characters escaped in the pattern and the replacement of tar --transform
+	sedPatternChars     = `\.[]*^$|`
+	sedReplacementChars = `\&|`
+)
+
+type (
+	// mapping copies the files matching Source into Target. StripComponents
+	// is -1 when the mapping uses the strip_components setting.
+	mapping struct {
+		Source          string
+		Target          string
+		StripComponents int
+	}
+
+	// renaming names a single source file differently in the archive.
+	renaming struct {
+		from, to string
+	}
+)
+
+// parseMapping parses "dist/web/* -> /var/www/html strip=2".
+func parseMapping(value string) (mapping, error) {
+	src, dest, found := strings.Cut(value, "->")
+	src, dest = strings.TrimSpace(src), strings.TrimSpace(dest)
+	if !found || src == "" || dest == "" {
+		return mapping{}, fmt.Errorf("%w: %q", errInvalidMapping, value)
+	}
+
+	m := mapping{Source: src, Target: dest, StripComponents: -1}
+	fields := strings.Fields(dest)
+	for len(fields) > 1 {
+		option, arg, ok := strings.Cut(fields[len(fields)-1], "=")
+		if !ok || option != "strip" {
+			break
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return mapping{}, fmt.Errorf("%w: %q", errInvalidMapping, value)
+		}
+		m.StripComponents = n
+		fields = fields[:len(fields)-1]
+		m.Target = strings.TrimSpace(strings.Join(fields, " "))
+	}
+	return m, nil
+}
+
+// rename reports how a mapping of a single file to a file path renames it.
+// A target ending with a slash is always a folder.
+func (m mapping) rename() (string, *renaming) {
+	if strings.ContainsAny(m.Source, "*?[") || strings.HasSuffix(m.Target, "/") || strings.HasSuffix(m.Target, "\\") {
+		return m.Target, nil
+	}
+	if info, err := os.Stat(m.Source); err != nil || !info.Mode().IsRegular() {
+		return m.Target, nil
+	}
+
+	i := strings.LastIndexAny(m.Target, "/\\")
+	dir := "."
+	switch {
+	case i == 0 || i > 0 && m.Target[i-1] == ':':
+		// keep the root folder, e.g. "/" or "C:\"
+		dir = m.Target[:i+1]
+	case i > 0:
+		dir = m.Target[:i]
+	}
+	name := m.Target[i+1:]
+	return dir, &renaming{from: archiveName(filepath.Clean(m.Source)), to: name}
+}
+
+// sedEscape escapes the characters of s which are special in a sed expression.
+func sedEscape(s, special string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune(special, r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// transformArgs returns the tar option renaming the archive member.
+func (r *renaming) transformArgs() []string {
+	if r == nil {
+		return nil
+	}
+	return []string{
+		"--transform",
+		fmt.Sprintf("s|^/*%s$|%s|", sedEscape(r.from, sedPatternChars), sedEscape(r.to, sedReplacementChars)),
+	}
+}
+
+// mappingJobs returns a transfer for every mapping, sharing all other settings.
+func (p *Plugin) mappingJobs() ([]job, error) {
+	if len(p.Config.Source) > 0 || len(p.Config.Target) > 0 {
+		return nil, errMappingWithSource
+	}
+
+	var jobs []job
+	for _, v := range trimValues(p.Config.Mapping) {
+		m, err := parseMapping(v)
+		if err != nil {
+			return nil, err
+		}
+
+		config := p.Config
+		config.Mapping = nil
+		config.Source = []string{m.Source}
+		if m.StripComponents >= 0 {
+			config.StripComponents = m.StripComponents
+		}
+
+		target, rename := m.rename()
+		if rename != nil {
+			if m.StripComponents > 0 {
+				return nil, fmt.Errorf("%w: %q", errRenameWithStrip, v)
+			}
+			// the renamed member has no folders to strip
+			config.Source = []string{filepath.Clean(m.Source)}
+			config.StripComponents = 0
+		}
+		config.Target = []string{target}
+		// the webhooks are notified once for all mappings
+		config.Webhooks = nil
+		jobs = append(jobs, job{
+			Name:   m.Source + " -> " + m.Target,
+			Plugin: Plugin{Config: config, rename: rename, collect: p.reporting()},
+		})
+	}
+
+	// every mapping only knows its own files and would remove the others
+	if p.Config.Sync || p.Config.Incremental || p.Config.Manifest {
+		for i := range jobs {
+			for k := i + 1; k < len(jobs); k++ {
+				a, b := jobs[i].Plugin.Config.Target[0], jobs[k].Plugin.Config.Target[0]
+				if nestedTarget(a, b) || nestedTarget(b, a) {
+					return nil, fmt.Errorf("%w: %q and %q", errMappingOverlap, jobs[i].Name, jobs[k].Name)
+				}
+			}
+		}
+	}
+	return jobs, nil
+}
+
+// nestedTarget reports whether the remote folder target is parent or inside it.
+func nestedTarget(target, parent string) bool {
+	target = path.Clean(strings.ReplaceAll(target, "\\", "/"))
+	parent = path.Clean(strings.ReplaceAll(parent, "\\", "/"))
+	return target == parent || parent == "/" && path.IsAbs(target) || strings.HasPrefix(target, parent+"/")
+}
+
+// execMappings copies every mapping in turn. The reports of the mappings are
+// combined, kept in result when collected, and the webhooks are notified once
+// for the whole run.
+func (p *Plugin) execMappings() error {
+	jobs, err := p.mappingJobs()
+	if err != nil {
+		return err
+	}
+	if _, err := p.webhookTemplate(); err != nil {
+		return err
+	}
+
+	all := make([]*job, 0, len(jobs))
+	run := *p
+	for i := range jobs {
+		all = append(all, &jobs[i])
+		run.Config.Target = append(run.Config.Target, jobs[i].Plugin.Config.Target...)
+	}
+
+	run.notify(webhookEvent{Event: webhookStart})
+	err = execJobs(jobs, false)
+
+	event := webhookEvent{Event: webhookSuccess}
+	event.Report, _ = combineJobs(all)
+	if p.collect {
+		// the job running the mappings reports them with the other jobs
+		p.result = event.Report
+	} else if err := writeJobReports(jobs); err != nil {
+		fmt.Println("drone-scp report error:", err)
+	}
+	if err != nil {
+		event.Event = webhookFailure
+		event.Error = strings.TrimSpace(err.Error())
+	}
+	run.notify(event)
+	return err
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMapping(t *testing.T) {
+	m, err := parseMapping("dist/web/* -> /var/www/html")
+	assert.NoError(t, err)
+	assert.Equal(t, mapping{Source: "dist/web/*", Target: "/var/www/html", StripComponents: -1}, m)
+
+	m, err = parseMapping(" dist/web/* ->/var/www/my app strip=2 ")
+	assert.NoError(t, err)
+	assert.Equal(t, mapping{Source: "dist/web/*", Target: "/var/www/my app", StripComponents: 2}, m)
+
+	for _, v := range []string{"dist/web/*", "-> /var/www", "dist ->", "dist -> /var/www strip=x", "dist -> /var/www strip=-1"} {
+		_, err := parseMapping(v)
+		assert.ErrorIs(t, err, errInvalidMapping, v)
+	}
+}
+
+func TestMappingRename(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prod.env")
+	assert.NoError(t, os.WriteFile(file, []byte("A=1"), 0o600))
+
+	target, rename := mapping{Source: file, Target: "/etc/app/.env"}.rename()
+	assert.Equal(t, "/etc/app", target)
+	assert.Equal(t, &renaming{from: archiveName(file), to: ".env"}, rename)
+
+	target, rename = mapping{Source: file, Target: "/.env"}.rename()
+	assert.Equal(t, "/", target)
+	assert.Equal(t, ".env", rename.to)
+
+	target, rename = mapping{Source: file, Target: ".env"}.rename()
+	assert.Equal(t, ".", target)
+	assert.Equal(t, ".env", rename.to)
+
+	target, rename = mapping{Source: file, Target: "/etc/app/"}.rename()
+	assert.Equal(t, "/etc/app/", target)
+	assert.Nil(t, rename)
+
+	target, rename = mapping{Source: dir, Target: "/etc/app"}.rename()
+	assert.Equal(t, "/etc/app", target)
+	assert.Nil(t, rename)
+
+	_, rename = mapping{Source: filepath.Join(dir, "*.env"), Target: "/etc/app/.env"}.rename()
+	assert.Nil(t, rename)
+}
+
+func TestTransformArgs(t *testing.T) {
+	var r *renaming
+	assert.Nil(t, r.transformArgs())
+
+	r = &renaming{from: "config/prod.env", to: "a&b|.env"}
+	assert.Equal(t, []string{"--transform", `s|^/*config/prod\.env$|a\&b\|.env|`}, r.transformArgs())
+
+	if _, err := exec.LookPath("tar"); err != nil {
+		t.Skip("tar not found")
+	}
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "config"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config", "prod.env"), []byte("A=1"), 0o600))
+	args := append([]string{"-C", dir}, r.transformArgs()...)
+	out, err := exec.Command("tar", append(args, "-cf", "-", "config/prod.env")...).Output()
+	assert.NoError(t, err)
+	list := exec.Command("tar", "-tf", "-")
+	list.Stdin = strings.NewReader(string(out))
+	names, err := list.Output()
+	assert.NoError(t, err)
+	assert.Equal(t, "a&b|.env\n", string(names))
+}
+
+func TestMappingJobs(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prod.env")
+	assert.NoError(t, os.WriteFile(file, []byte("A=1"), 0o600))
+
+	p := Plugin{
+		Config: Config{
+			Host:            []string{"example.com"},
+			StripComponents: 1,
+			Mapping:         []string{"dist/* -> /var/www", "dist/* -> /srv strip=0", file + " -> /etc/app/.env"},
+		},
+	}
+	jobs, err := p.mappingJobs()
+	assert.NoError(t, err)
+	assert.Len(t, jobs, 3)
+
+	assert.Equal(t, "dist/* -> /var/www", jobs[0].Name)
+	assert.Equal(t, []string{"dist/*"}, jobs[0].Plugin.Config.Source)
+	assert.Equal(t, []string{"/var/www"}, jobs[0].Plugin.Config.Target)
+	assert.Equal(t, 1, jobs[0].Plugin.Config.StripComponents)
+	assert.Nil(t, jobs[0].Plugin.Config.Mapping)
+	assert.Equal(t, []string{"example.com"}, jobs[0].Plugin.Config.Host)
+
+	assert.Equal(t, 0, jobs[1].Plugin.Config.StripComponents)
+
+	assert.Equal(t, []string{"/etc/app"}, jobs[2].Plugin.Config.Target)
+	assert.Equal(t, 0, jobs[2].Plugin.Config.StripComponents)
+	assert.Equal(t, ".env", jobs[2].Plugin.rename.to)
+	assert.Equal(t, []localFile{{Name: ".env"}}, jobs[2].Plugin.relativeFiles([]localFile{{Name: archiveName(file)}}))
+
+	p.Config.Mapping = []string{file + " -> /etc/app/.env strip=1"}
+	_, err = p.mappingJobs()
+	assert.ErrorIs(t, err, errRenameWithStrip)
+
+	p.Config.Target = []string{"/var/www"}
+	_, err = p.mappingJobs()
+	assert.Equal(t, errMappingWithSource, err)
+}
+
+func TestMappingJobsOverlap(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "prod.env")
+	assert.NoError(t, os.WriteFile(file, []byte("A=1"), 0o600))
+
+	p := Plugin{Config: Config{Mapping: []string{"dist/web/** -> /var/www/html", file + " -> /var/www/html/.env"}}}
+	_, err := p.mappingJobs()
+	assert.NoError(t, err)
+
+	// the second mapping would remove the files of the first one
+	for _, config := range []Config{{Sync: true}, {Incremental: true}, {Manifest: true}} {
+		config.Mapping = p.Config.Mapping
+		_, err := (&Plugin{Config: config}).mappingJobs()
+		assert.ErrorIs(t, err, errMappingOverlap)
+	}
+
+	p.Config.Sync = true
+	p.Config.Mapping = []string{"dist/web/** -> /var/www", "dist/api/** -> /var/www/api/"}
+	_, err = p.mappingJobs()
+	assert.ErrorIs(t, err, errMappingOverlap)
+
+	p.Config.Mapping = []string{"dist/web/** -> /var/www/html", "dist/api/** -> /var/www/html-api"}
+	_, err = p.mappingJobs()
+	assert.NoError(t, err)
+}
+
+func TestNestedTarget(t *testing.T) {
+	assert.True(t, nestedTarget("/var/www", "/var/www/"))
+	assert.True(t, nestedTarget("/var/www/html", "/var/www"))
+	assert.True(t, nestedTarget("/srv", "/"))
+	assert.True(t, nestedTarget(`C:\www\html`, `C:\www`))
+	assert.False(t, nestedTarget("/var/www", "/var/www/html"))
+	assert.False(t, nestedTarget("/var/www-old", "/var/www"))
+	assert.False(t, nestedTarget("www", "/"))
+}
+
+func TestExecMappingsReport(t *testing.T) {
+	server, requests := webhookServer(t)
+	dir := t.TempDir()
+	p := Plugin{
+		Config: Config{
+			Host:           []string{"127.0.0.1:1"},
+			Port:           22,
+			Protocol:       easyssh.PROTOCOL_TCP,
+			Username:       "drone-scp",
+			Password:       "1234",
+			Timeout:        time.Second,
+			CommandTimeout: time.Second,
+			Mapping:        []string{"tests/a.txt -> /var/www/", "tests/b.txt -> /srv/"},
+			TarExec:        "tar",
+			TarTmpPath:     dir + "/",
+			ReportFile:     filepath.Join(dir, "report.json"),
+			Webhooks:       []string{server.URL},
+		},
+	}
+
+	assert.Error(t, p.Exec())
+
+	// a single start and end event for all mappings
+	assert.Len(t, requests(), 2)
+	var start, end webhookEvent
+	assert.NoError(t, json.Unmarshal(requests()[0].body, &start))
+	assert.NoError(t, json.Unmarshal(requests()[1].body, &end))
+	assert.Equal(t, webhookStart, start.Event)
+	assert.Equal(t, []string{"/var/www/", "/srv/"}, start.Targets)
+	assert.Equal(t, webhookFailure, end.Event)
+	assert.Equal(t, statusFailure, end.Report.Status)
+
+	data, err := os.ReadFile(filepath.Join(dir, "report.json"))
+	assert.NoError(t, err)
+	var rep report
+	assert.NoError(t, json.Unmarshal(data, &rep))
+	assert.Equal(t, statusFailure, rep.Status)
+	assert.Len(t, rep.Hosts, 1)
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/crypto/ssh"
+)
+
+var (
+	errPingFailed = errors.New("ping found problems on at least one host")
+	// errHandshakeDone stops the throwaway handshake once the host key is known.
+	errHandshakeDone = errors.New("host key received")
+)
+
+// proxyConfig returns the connection settings of the proxy host.
+func proxyConfig(proxy easyssh.DefaultConfig) *easyssh.MakeConfig {
+	return &easyssh.MakeConfig{
+		Server:            proxy.Server,
+		User:              proxy.User,
+		Password:          proxy.Password,
+		Port:              proxy.Port,
+		Protocol:          proxy.Protocol,
+		Key:               proxy.Key,
+		KeyPath:           proxy.KeyPath,
+		Passphrase:        proxy.Passphrase,
+		Timeout:           proxy.Timeout,
+		Ciphers:           proxy.Ciphers,
+		Fingerprint:       proxy.Fingerprint,
+		UseInsecureCipher: proxy.UseInsecureCipher,
+	}
+}
+
+// dial opens a connection to the host, through the proxy if one is configured.
+func dial(cfg *easyssh.MakeConfig) (net.Conn, func(), error) {
+	protocol := string(cfg.Protocol)
+	if protocol == "" {
+		protocol = string(easyssh.PROTOCOL_TCP)
+	}
+	addr := net.JoinHostPort(cfg.Server, cfg.Port)
+
+	if cfg.Proxy.Server == "" {
+		conn, err := net.DialTimeout(protocol, addr, cfg.Timeout)
+		return conn, func() {}, err
+	}
+
+	session, client, err := proxyConfig(cfg.Proxy).Connect()
+	if err != nil {
+		return nil, nil, fmt.Errorf("proxy: %w", err)
+	}
+	session.Close()
+	conn, err := client.Dial(protocol, addr)
+	if err != nil {
+		client.Close()
+		return nil, nil, err
+	}
+	return conn, func() { client.Close() }, nil
+}
+
+// hostKey runs a throwaway handshake and returns the host key of the server.
+func hostKey(cfg *easyssh.MakeConfig) (ssh.PublicKey, time.Duration, error) {
+	start := time.Now()
+	conn, closeProxy, err := dial(cfg)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer closeProxy()
+	defer conn.Close()
+	latency := time.Since(start)
+
+	var key ssh.PublicKey
+	_, _, _, err = ssh.NewClientConn(conn, net.JoinHostPort(cfg.Server, cfg.Port), &ssh.ClientConfig{
+		User: cfg.User,
+		HostKeyCallback: func(hostname string, remote net.Addr, publicKey ssh.PublicKey) error {
+			key = publicKey
+			return errHandshakeDone
+		},
+		Timeout: cfg.Timeout,
+	})
+	if key == nil {
+		return nil, latency, err
+	}
+	return key, latency, nil
+}
+
+// authMethods returns a config for every configured credential, each using only that credential.
+func authMethods(cfg *easyssh.MakeConfig) map[string]*easyssh.MakeConfig {
+	methods := map[string]*easyssh.MakeConfig{}
+	only := func(name string, set func(c *easyssh.MakeConfig)) {
+		c := *cfg
+		c.Password, c.Key, c.KeyPath = "", "", ""
+		set(&c)
+		methods[name] = &c
+	}
+	if cfg.Password != "" {
+		only("password", func(c *easyssh.MakeConfig) { c.Password = cfg.Password })
+	}
+	if cfg.Key != "" {
+		only("key", func(c *easyssh.MakeConfig) { c.Key = cfg.Key })
+	}
+	if cfg.KeyPath != "" {
+		only("key-path", func(c *easyssh.MakeConfig) { c.KeyPath = cfg.KeyPath })
+	}
+	return methods
+}
+
+// pingHost reports the host key, latency, usable credentials, remote tar and
+// write access of a host, returning false when a check failed.
+func (p *Plugin) pingHost(cfg *easyssh.MakeConfig) bool {
+	host := cfg.Server
+	if cfg.Proxy.Server != "" {
+		p.log(host, "connect through proxy", net.JoinHostPort(cfg.Proxy.Server, cfg.Proxy.Port))
+	}
+
+	key, latency, err := hostKey(cfg)
+	if err != nil {
+		p.log(host, "✗ connect:", err)
+		return false
+	}
+	fingerprint := ssh.FingerprintSHA256(key)
+	switch {
+	case p.Config.Fingerprint == "":
+		p.log(host, "host key", key.Type(), fingerprint)
+	case p.Config.Fingerprint == fingerprint:
+		p.log(host, "✓ host key", key.Type(), fingerprint, "matches the fingerprint")
+	default:
+		p.log(host, "✗ host key", key.Type(), fingerprint, "does not match the fingerprint", p.Config.Fingerprint)
+		return false
+	}
+
+	ok := false
+	methods := authMethods(cfg)
+	for _, name := range []string{"password", "key", "key-path"} {
+		method, found := methods[name]
+		if !found {
+			continue
+		}
+		session, client, err := method.Connect()
+		if err != nil {
+			p.log(host, "✗ auth", name+":", err)
+			continue
+		}
+		session.Close()
+		client.Close()
+		p.log(host, "✓ auth", name, "as", cfg.User)
+		ok = true
+	}
+	if !ok {
+		return false
+	}
+
+	start := time.Now()
+	if _, _, _, err := cfg.Run("echo", p.Config.CommandTimeout); err != nil {
+		p.log(host, "✗ run command:", err)
+		return false
+	}
+	p.log(host, fmt.Sprintf("latency: dial %s, connect and run command %s",
+		latency.Round(time.Microsecond), time.Since(start).Round(time.Microsecond)))
+
+	systemType := p.systemType(cfg)
+	p.log(host, "remote server os type is "+systemType)
+	if outStr, _, _, err := p.run(cfg, p.Config.TarExec+" --version"); err != nil {
+		p.log(host, "✗ tar:", err)
+		ok = false
+	} else {
+		version, _, _ := strings.Cut(strings.TrimSpace(outStr), "\n")
+		p.log(host, "✓ tar:", strings.TrimSpace(version))
+	}
+	if comp, err := p.compression(); err == nil && p.hostCompression(systemType, cfg, comp) == comp {
+		p.log(host, "✓ tar supports", comp.Name)
+	}
+
+	return p.checkWritable(systemType, cfg) && ok
+}
+
+// Ping checks the connection to every host.
+func (p *Plugin) Ping() error {
+	hosts := trimValues(p.Config.Host)
+	if len(hosts) == 0 {
+		return errMissingHost
+	}
+
+	failed := false
+	for _, h := range hosts {
+		if !p.pingHost(p.sshConfig(h)) {
+			failed = true
+		}
+	}
+	if failed {
+		return errPingFailed
+	}
+	return nil
+}
+
+func pingCommand(c *cli.Context) error {
+	p := newPlugin(c)
+	return p.Ping()
+}
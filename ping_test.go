@@ -0,0 +1,41 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthMethods(t *testing.T) {
+	cfg := &easyssh.MakeConfig{
+		User:     "deploy",
+		Password: "secret",
+		KeyPath:  "/root/.ssh/id_rsa",
+	}
+
+	methods := authMethods(cfg)
+	assert.Len(t, methods, 2)
+	assert.Equal(t, "secret", methods["password"].Password)
+	assert.Empty(t, methods["password"].KeyPath)
+	assert.Equal(t, "/root/.ssh/id_rsa", methods["key-path"].KeyPath)
+	assert.Empty(t, methods["key-path"].Password)
+	assert.Equal(t, "deploy", methods["key-path"].User)
+	assert.Equal(t, "secret", cfg.Password)
+}
+
+func TestPingUnreachableHost(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Host:     []string{"127.0.0.1"},
+			Port:     1,
+			Protocol: easyssh.PROTOCOL_TCP,
+			Password: "secret",
+			Target:   []string{"/tmp"},
+		},
+	}
+	assert.Equal(t, errPingFailed, p.Ping())
+
+	p.Config.Host = nil
+	assert.Equal(t, errMissingHost, p.Ping())
+}
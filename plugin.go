@@ -13,7 +13,9 @@ import (
 
 	"github.com/appleboy/com/random"
 	"github.com/appleboy/easyssh-proxy"
+	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fatih/color"
+	"golang.org/x/time/rate"
 )
 
 var (
@@ -25,36 +27,98 @@ var (
 type (
 	// Config for the plugin.
 	Config struct {
-		Host              []string
-		Port              int
-		Protocol          easyssh.Protocol
-		Username          string
-		Password          string
-		Key               string
-		Passphrase        string
-		Fingerprint       string
-		KeyPath           string
-		Timeout           time.Duration
-		CommandTimeout    time.Duration
-		Target            []string
-		Source            []string
-		Remove            bool
-		StripComponents   int
-		TarExec           string
-		TarTmpPath        string
-		Proxy             easyssh.DefaultConfig
-		Debug             bool
-		Overwrite         bool
-		UnlinkFirst       bool
-		Ciphers           []string
-		UseInsecureCipher bool
-		TarDereference    bool
+		Host               []string              `yaml:"host"`
+		Port               int                   `yaml:"port"`
+		Protocol           easyssh.Protocol      `yaml:"protocol"`
+		Username           string                `yaml:"username"`
+		Password           string                `yaml:"password"`
+		Key                string                `yaml:"key"`
+		Passphrase         string                `yaml:"passphrase"`
+		Fingerprint        string                `yaml:"fingerprint"`
+		KeyPath            string                `yaml:"key_path"`
+		Timeout            time.Duration         `yaml:"timeout"`
+		CommandTimeout     time.Duration         `yaml:"command_timeout"`
+		Target             []string              `yaml:"target"`
+		Source             []string              `yaml:"source"`
+		Remove             bool                  `yaml:"rm"`
+		StripComponents    int                   `yaml:"strip_components"`
+		TarExec            string                `yaml:"tar_exec"`
+		TarTmpPath         string                `yaml:"tar_tmp_path"`
+		Proxy              easyssh.DefaultConfig `yaml:"-"`
+		Debug              bool                  `yaml:"debug"`
+		Overwrite          bool                  `yaml:"overwrite"`
+		UnlinkFirst        bool                  `yaml:"unlink_first"`
+		Ciphers            []string              `yaml:"ciphers"`
+		UseInsecureCipher  bool                  `yaml:"use_insecure_cipher"`
+		TarDereference     bool                  `yaml:"tar_dereference"`
+		Sync               bool                  `yaml:"sync"`
+		SyncExclude        []string              `yaml:"sync_exclude"`
+		Incremental        bool                  `yaml:"incremental"`
+		Delta              bool                  `yaml:"delta"`
+		DeltaMinSize       int64                 `yaml:"delta_min_size"`
+		Relay              string                `yaml:"relay"`
+		RelayKeyPath       string                `yaml:"relay_key_path"`
+		Compression        string                `yaml:"compression"`
+		CompressionLevel   int                   `yaml:"compression_level"`
+		CompressionWorkers int                   `yaml:"compression_workers"`
+		VerifyChecksum     bool                  `yaml:"verify_checksum"`
+		VerifyFiles        bool                  `yaml:"verify_files"`
+		Resume             bool                  `yaml:"resume"`
+		ChunkSize          int64                 `yaml:"chunk_size"`
+		Retries            int                   `yaml:"retries"`
+		Backoff            time.Duration         `yaml:"retry_backoff"`
+		MaxBackoff         time.Duration         `yaml:"retry_max_backoff"`
+		RetryOn            []string              `yaml:"retry_on"`
+		BandwidthLimit     string                `yaml:"bandwidth_limit"`
+		BandwidthTotal     string                `yaml:"bandwidth_limit_total"`
+		ProgressInterval   time.Duration         `yaml:"progress_interval"`
+		ReportFile         string                `yaml:"report_file"`
+		JUnitFile          string                `yaml:"junit_file"`
+		GitHubSummary      string                `yaml:"github_step_summary"`
+		GitHubOutput       string                `yaml:"github_output"`
+		CardPath           string                `yaml:"card_path"`
+		Webhooks           []string              `yaml:"webhook"`
+		WebhookSecret      string                `yaml:"webhook_secret"`
+		WebhookTemplate    string                `yaml:"webhook_template"`
+		DryRun             bool                  `yaml:"dry_run"`
+		Manifest           bool                  `yaml:"manifest"`
+		Mapping            []string              `yaml:"mapping"`
+		Exclude            []string              `yaml:"exclude"`
+		IgnoreFile         []string              `yaml:"ignore_file"`
+		MinSize            string                `yaml:"min_size"`
+		MaxSize            string                `yaml:"max_size"`
+		ModifiedSince      string                `yaml:"modified_since"`
+		NoSymlinks         bool                  `yaml:"no_symlinks"`
+		NoEmptyDirs        bool                  `yaml:"no_empty_dirs"`
+		NoHidden           bool                  `yaml:"no_hidden"`
+		RegularOnly        bool                  `yaml:"regular_only"`
+		ChangedSince       string                `yaml:"changed_since"`
+		ChangedSinceDelete bool                  `yaml:"changed_since_delete"`
 	}
 
 	// Plugin values.
 	Plugin struct {
 		Config   Config
 		DestFile string
+
+		// bandwidth is the upload limit per host in bytes per second.
+		bandwidth int64
+		// totalBandwidth is shared by all hosts.
+		totalBandwidth *rate.Limiter
+		// sent counts the uploaded bytes per host.
+		sent *transferStats
+		// rename names the single source file differently on the host.
+		rename *renaming
+		// changes restricts the sources to the files changed in git.
+		changes *gitChanges
+		// revision is the commit recorded in the targets by changed_since.
+		revision string
+		// parts caches the names of the partial uploads in resumable mode.
+		parts *onceCache
+		// collect keeps the report in result instead of writing it, for the
+		// caller combining the reports of several jobs.
+		collect bool
+		result  *report
 	}
 
 	copyError struct {
@@ -77,7 +141,14 @@ func globList(paths []string) fileList {
 			pattern = pattern[1:]
 			ignore = true
 		}
-		matches, err := filepath.Glob(pattern)
+		glob := filepath.Glob
+		if strings.Contains(pattern, "**") {
+			// match any number of folders
+			glob = func(pattern string) ([]string, error) {
+				return doublestar.FilepathGlob(pattern)
+			}
+		}
+		matches, err := glob(pattern)
 		if err != nil {
 			fmt.Printf("Glob error for %q: %s\n", pattern, err)
 			continue
@@ -86,6 +157,9 @@ func globList(paths []string) fileList {
 		if ignore {
 			list.Ignore = append(list.Ignore, matches...)
 		} else {
+			if strings.Contains(pattern, "**") {
+				matches = outermost(matches)
+			}
 			list.Source = append(list.Source, matches...)
 		}
 	}
@@ -93,6 +167,35 @@ func globList(paths []string) fileList {
 	return list
 }
 
+// outermost drops the matches inside another matched folder, which tar adds
+// with all its content. The current folder is left out, so its entries keep
+// their names without the "./" prefix.
+func outermost(matches []string) []string {
+	matched := map[string]bool{}
+	for _, m := range matches {
+		matched[filepath.Clean(m)] = true
+	}
+
+	var list []string
+	for _, m := range matches {
+		m = filepath.Clean(m)
+		if m == "." {
+			continue
+		}
+		nested := false
+		for dir := filepath.Dir(m); dir != "." && !nested; dir = filepath.Dir(dir) {
+			nested = matched[dir]
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+		if !nested {
+			list = append(list, m)
+		}
+	}
+	return list
+}
+
 func (p Plugin) log(host string, message ...interface{}) {
 	if count := len(p.Config.Host); count == 1 {
 		fmt.Printf("%s", fmt.Sprintln(message...))
@@ -103,7 +206,7 @@ func (p Plugin) log(host string, message ...interface{}) {
 
 func (p *Plugin) removeDestFile(os string, ssh *easyssh.MakeConfig) error {
 	p.log(ssh.Server, "remove file", p.DestFile)
-	_, errStr, _, err := ssh.Run(rmcmd(os, p.DestFile), p.Config.CommandTimeout)
+	_, errStr, _, err := p.run(ssh, rmcmd(os, p.DestFile))
 	if err != nil {
 		return err
 	}
@@ -117,44 +220,12 @@ func (p *Plugin) removeDestFile(os string, ssh *easyssh.MakeConfig) error {
 
 func (p *Plugin) removeAllDestFile() error {
 	for _, h := range trimValues(p.Config.Host) {
-		host, port := p.hostPort(h)
-		ssh := &easyssh.MakeConfig{
-			Server:            host,
-			User:              p.Config.Username,
-			Password:          p.Config.Password,
-			Port:              port,
-			Protocol:          p.Config.Protocol,
-			Key:               p.Config.Key,
-			KeyPath:           p.Config.KeyPath,
-			Passphrase:        p.Config.Passphrase,
-			Timeout:           p.Config.Timeout,
-			Ciphers:           p.Config.Ciphers,
-			Fingerprint:       p.Config.Fingerprint,
-			UseInsecureCipher: p.Config.UseInsecureCipher,
-			Proxy: easyssh.DefaultConfig{
-				Server:            p.Config.Proxy.Server,
-				User:              p.Config.Proxy.User,
-				Password:          p.Config.Proxy.Password,
-				Port:              p.Config.Proxy.Port,
-				Protocol:          p.Config.Proxy.Protocol,
-				Key:               p.Config.Proxy.Key,
-				KeyPath:           p.Config.Proxy.KeyPath,
-				Passphrase:        p.Config.Proxy.Passphrase,
-				Timeout:           p.Config.Proxy.Timeout,
-				Ciphers:           p.Config.Proxy.Ciphers,
-				Fingerprint:       p.Config.Proxy.Fingerprint,
-				UseInsecureCipher: p.Config.Proxy.UseInsecureCipher,
-			},
-		}
+		ssh := p.sshConfig(h)
 
-		_, _, _, err := ssh.Run("ver", p.Config.CommandTimeout)
-		systemType := "unix"
-		if err == nil {
-			systemType = "windows"
-		}
+		systemType := p.systemType(ssh)
 
 		// remove tar file
-		err = p.removeDestFile(systemType, ssh)
+		err := p.removeDestFile(systemType, ssh)
 		if err != nil {
 			return err
 		}
@@ -166,10 +237,18 @@ func (p *Plugin) removeAllDestFile() error {
 type fileList struct {
 	Ignore []string
 	Source []string
+	// Rules exclude entries with the gitignore semantics.
+	Rules ignoreRules
+	// Filter selects entries by size, age and type.
+	Filter *fileFilter
 }
 
 func (p *Plugin) buildTarArgs(src string) []string {
-	files := globList(trimValues(p.Config.Source))
+	c, _ := p.compression()
+	return p.tarArgs(c, src, globList(trimValues(p.Config.Source)))
+}
+
+func (p *Plugin) tarArgs(c compression, src string, files fileList) []string {
 	args := []string{}
 	if len(files.Ignore) > 0 {
 		for _, v := range files.Ignore {
@@ -182,21 +261,31 @@ func (p *Plugin) buildTarArgs(src string) []string {
 		args = append(args, "--dereference")
 	}
 
-	args = append(args, "-zcf")
-	args = append(args, getRealPath(src))
+	args = append(args, p.rename.transformArgs()...)
+
+	args = append(args, c.createArgs(p.Config.CompressionLevel)...)
+	if src == "-" {
+		// write the archive to stdout
+		args = append(args, src)
+	} else {
+		args = append(args, getRealPath(src))
+	}
 	args = append(args, files.Source...)
 
 	return args
 }
 
 func (p *Plugin) buildUnTarArgs(target string) []string {
+	c, _ := p.compression()
+	return p.untarArgs(c, p.DestFile, target)
+}
+
+func (p *Plugin) untarArgs(c compression, file, target string) []string {
 	args := []string{}
 
-	args = append(args,
-		p.Config.TarExec,
-		"-zxf",
-		p.DestFile,
-	)
+	args = append(args, p.Config.TarExec)
+	args = append(args, c.extractArgs()...)
+	args = append(args, file)
 
 	if p.Config.StripComponents > 0 {
 		args = append(args, "--strip-components")
@@ -219,8 +308,23 @@ func (p *Plugin) buildUnTarArgs(target string) []string {
 	return args
 }
 
+// runTar creates the local archive.
+func (p *Plugin) runTar(args []string) error {
+	cmd := exec.Command(p.Config.TarExec, args...)
+	if p.Config.Debug {
+		fmt.Println("$", strings.Join(cmd.Args, " "))
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
 // Exec executes the plugin.
-func (p *Plugin) Exec() error {
+func (p *Plugin) Exec() (err error) {
+	if len(p.Config.Mapping) > 0 {
+		return p.execMappings()
+	}
+
 	if len(p.Config.Key) == 0 && len(p.Config.Password) == 0 && len(p.Config.KeyPath) == 0 {
 		return errMissingPasswordOrKey
 	}
@@ -234,135 +338,328 @@ func (p *Plugin) Exec() error {
 		return errMissingHost
 	}
 
-	p.DestFile = random.String(10) + ".tar.gz"
+	if p.Config.Sync && p.Config.Remove {
+		return errSyncWithRemove
+	}
+
+	if p.Config.Delta && !p.Config.Incremental {
+		return errDeltaWithoutIncremental
+	}
+
+	if p.Config.Relay != "" && p.Config.Incremental {
+		return errRelayWithIncremental
+	}
+
+	relay, err := p.relayConfig(hosts)
+	if err != nil {
+		return err
+	}
+
+	comp, err := p.compression()
+	if err != nil {
+		return err
+	}
+
+	if _, err := p.retryClasses(); err != nil {
+		return err
+	}
+
+	if _, err := p.webhookTemplate(); err != nil {
+		return err
+	}
+
+	if p.changes, err = p.gitChanges(hosts); err != nil {
+		return err
+	}
+
+	files, err := p.sourceList()
+	if err != nil {
+		return err
+	}
+	deleted := p.deletedFiles(files)
+
+	if p.bandwidth, err = parseBandwidth(p.Config.BandwidthLimit); err != nil {
+		return err
+	}
+	total, err := parseBandwidth(p.Config.BandwidthTotal)
+	if err != nil {
+		return err
+	}
+	p.totalBandwidth = newLimiter(total)
+	p.sent = &transferStats{}
+	p.parts = &onceCache{}
+	rep := p.newReport(hosts)
+
+	p.DestFile = random.String(10) + comp.Ext
 
 	// create a temporary file for the archive
 	dir := os.TempDir()
 	src := filepath.Join(dir, p.DestFile)
 
+	// upload file to the tmp path
+	p.DestFile = p.Config.TarTmpPath + p.DestFile
+
+	if p.Config.DryRun {
+		return p.dryRun(hosts, comp, files)
+	}
+
+	// the source list which is compared with the target folders
+	var local []localFile
+
+	p.notify(webhookEvent{Event: webhookStart})
+	defer func() {
+		if !p.reporting() {
+			return
+		}
+		rep.finish(p, src, local, err)
+		if p.collect {
+			p.result = rep
+		} else if err := p.writeReports(rep); err != nil {
+			fmt.Println("drone-scp report error:", err)
+		}
+		event := webhookEvent{Event: webhookSuccess, Report: rep}
+		if err != nil {
+			event.Event = webhookFailure
+			event.Error = strings.TrimSpace(err.Error())
+		}
+		p.notify(event)
+	}()
+
 	// show current version
 	fmt.Println("drone-scp version: " + Version)
-	// run archive command
-	fmt.Println("tar all files into " + src)
-	args := p.buildTarArgs(src)
-	cmd := exec.Command(p.Config.TarExec, args...)
-	if p.Config.Debug {
-		fmt.Println("$", strings.Join(cmd.Args, " "))
+	// run archive command, incremental mode builds the archives per target
+	if !p.Config.Incremental {
+		fmt.Println("tar all files into " + src)
+		if err := p.createArchive(comp, src, nil, files); err != nil {
+			return err
+		}
+
 	}
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return err
+
+	// collect the source list which is compared with the target folders
+	if p.Config.Sync || p.Config.Incremental || p.Config.VerifyFiles || p.Config.Manifest || p.reporting() {
+		list, err := walkFiles(files, p.Config.TarDereference)
+		if err != nil {
+			return err
+		}
+		local = p.relativeFiles(list)
+	}
+
+	if relay != nil {
+		if err := p.uploadRelay(relay, src); err != nil {
+			return err
+		}
+		defer func() {
+			if err := p.removeRelayFile(relay); err != nil {
+				p.log(relay.Server, "remove relay file failed:", err)
+			}
+		}()
+	}
+
+	// gzip archive for hosts which can't extract the configured compression
+	fallback := &onceCache{}
+
+	// checksums of the local archives
+	sums := &onceCache{}
+
+	var state *incrementalState
+	if p.Config.Incremental || p.Config.VerifyFiles || p.Config.Manifest {
+		var err error
+		fmt.Println("hash all source files")
+		if state, err = newIncrementalState(local); err != nil {
+			return err
+		}
 	}
 
 	wg := sync.WaitGroup{}
-	wg.Add(len(p.Config.Host))
-	errChannel := make(chan error)
+	wg.Add(len(hosts))
+	errChannel := make(chan error, len(hosts))
 	finished := make(chan struct{})
-	for _, host := range hosts {
-		go func(h string) {
+	for i, host := range hosts {
+		go func(h string, hr *hostReport) {
 			defer wg.Done()
-			host, port := p.hostPort(h)
-			// Create MakeConfig instance with remote username, server address and path to private key.
-			ssh := &easyssh.MakeConfig{
-				Server:            host,
-				User:              p.Config.Username,
-				Password:          p.Config.Password,
-				Port:              port,
-				Key:               p.Config.Key,
-				KeyPath:           p.Config.KeyPath,
-				Passphrase:        p.Config.Passphrase,
-				Timeout:           p.Config.Timeout,
-				Ciphers:           p.Config.Ciphers,
-				Fingerprint:       p.Config.Fingerprint,
-				UseInsecureCipher: p.Config.UseInsecureCipher,
-				Proxy: easyssh.DefaultConfig{
-					Server:            p.Config.Proxy.Server,
-					User:              p.Config.Proxy.User,
-					Password:          p.Config.Proxy.Password,
-					Port:              p.Config.Proxy.Port,
-					Key:               p.Config.Proxy.Key,
-					KeyPath:           p.Config.Proxy.KeyPath,
-					Passphrase:        p.Config.Proxy.Passphrase,
-					Timeout:           p.Config.Proxy.Timeout,
-					Ciphers:           p.Config.Proxy.Ciphers,
-					Fingerprint:       p.Config.Proxy.Fingerprint,
-					UseInsecureCipher: p.Config.Proxy.UseInsecureCipher,
-				},
+			ssh := p.sshConfig(h)
+			host := ssh.Server
+			if p.Config.Delta {
+				defer p.removeDeltaHelper(ssh, &state.helpers)
 			}
-
-			systemType := "unix"
-			_, _, _, err := ssh.Run("ver", p.Config.CommandTimeout)
-			if err == nil {
-				systemType = "windows"
+			var err error
+			fail := func(err error) {
+				hr.finish(err)
+				errChannel <- err
 			}
 
-			// upload file to the tmp path
-			p.DestFile = fmt.Sprintf("%s%s", p.Config.TarTmpPath, p.DestFile)
+			hr.begin("connect")
+			systemType := p.systemType(ssh)
+			hr.OS = systemType
 
 			p.log(host, "remote server os type is "+systemType)
-			// Call Scp method with file you want to upload to remote server.
-			p.log(host, "scp file to server.")
-			err = ssh.Scp(src, p.DestFile)
-			if err != nil {
-				errChannel <- copyError{host, err.Error()}
-				return
+			hostComp := p.hostCompression(systemType, ssh, comp)
+			archive := src
+			if hostComp != comp && !p.Config.Incremental {
+				if relay != nil {
+					fail(fmt.Errorf("remote tar lacks %s support", comp.Name))
+					return
+				}
+				archive, err = fallback.get(hostComp.Name, func() (string, error) {
+					gz := strings.TrimSuffix(src, comp.Ext) + hostComp.Ext
+					fmt.Println("tar all files into " + gz)
+					return gz, p.createArchive(hostComp, gz, nil, files)
+				})
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+
+			hr.begin("upload")
+			if relay != nil {
+				if err := p.relayCopy(relay, ssh); err != nil {
+					fail(err)
+					return
+				}
+			} else if !p.Config.Incremental {
+				// Call Scp method with file you want to upload to remote server.
+				p.log(host, "scp file to server.")
+				err := p.retry(host, "upload", func() error {
+					return p.upload(systemType, ssh, archive, p.DestFile)
+				})
+				if err != nil {
+					fail(copyError{host, err.Error()})
+					return
+				}
+			}
+
+			if p.Config.VerifyChecksum && !p.Config.Incremental {
+				if err := p.verifyArchive(systemType, ssh, sums, archive, p.DestFile); err != nil {
+					fail(err)
+					return
+				}
 			}
 
 			for _, target := range p.Config.Target {
+				tr := hr.target(target)
 				target = strings.ReplaceAll(target, " ", "\\ ")
+				hr.begin("mkdir")
 				// remove target folder before upload data
 				if p.Config.Remove {
 					p.log(host, "Remove target folder:", target)
 
-					_, _, _, err := ssh.Run(rmcmd(systemType, target), p.Config.CommandTimeout)
+					_, _, _, err := p.run(ssh, rmcmd(systemType, target))
 					if err != nil {
-						errChannel <- err
+						fail(err)
 						return
 					}
 				}
 
 				p.log(host, "create folder", target)
-				_, errStr, _, err := ssh.Run(mkdircmd(systemType, target), p.Config.CommandTimeout)
+				_, errStr, _, err := p.run(ssh, mkdircmd(systemType, target))
+				tr.Stderr = errStr
 				if err != nil {
-					errChannel <- err
+					fail(err)
 					return
 				}
 
 				if len(errStr) != 0 {
-					errChannel <- fmt.Errorf("%s", errStr)
+					fail(fmt.Errorf("%s", errStr))
 					return
 				}
 
-				// untar file
-				p.log(host, "untar file", p.DestFile)
-				commamd := strings.Join(p.buildUnTarArgs(target), " ")
-				if p.Config.Debug {
-					fmt.Println("$", commamd)
+				var remote map[string]remoteFile
+				if p.Config.Sync {
+					remote, err = p.listRemoteFiles(systemType, ssh, target)
+					if err != nil {
+						fail(err)
+						return
+					}
+				}
+
+				hr.begin("untar")
+				if p.Config.Incremental {
+					err := p.incrementalTarget(systemType, ssh, target, hostComp, sums, state)
+					if err != nil {
+						fail(err)
+						return
+					}
+				} else {
+					// untar file
+					p.log(host, "untar file", p.DestFile)
+					commamd := strings.Join(p.untarArgs(hostComp, p.DestFile, target), " ")
+					if p.Config.Debug {
+						fmt.Println("$", commamd)
+					}
+					pr := p.startProgress(host, "extract "+target, 0)
+					outStr, errStr, _, err := p.run(ssh, commamd)
+					pr.Stop()
+					tr.Stderr = errStr
+
+					if outStr != "" {
+						p.log(host, "output: ", outStr)
+					}
+
+					if errStr != "" {
+						p.log(host, "error: ", errStr)
+					}
+
+					if err != nil {
+						fail(err)
+						return
+					}
+
+					// incremental mode always writes the manifest
+					if p.Config.Manifest {
+						if err := p.writeManifest(ssh, target, state.manifest); err != nil {
+							fail(err)
+							return
+						}
+					}
+				}
+
+				if len(deleted) > 0 {
+					hr.begin("delete")
+					if err := p.removeDeletedFiles(systemType, ssh, target, deleted); err != nil {
+						fail(err)
+						return
+					}
 				}
-				outStr, errStr, _, err := ssh.Run(commamd, p.Config.CommandTimeout)
 
-				if outStr != "" {
-					p.log(host, "output: ", outStr)
+				if p.Config.VerifyFiles {
+					hr.begin("verify")
+					if err := p.verifyFiles(systemType, ssh, target, state.manifest); err != nil {
+						fail(err)
+						return
+					}
 				}
 
-				if errStr != "" {
-					p.log(host, "error: ", errStr)
+				if p.Config.Sync {
+					hr.begin("sync")
+					if err := p.syncTarget(systemType, ssh, target, local, remote); err != nil {
+						fail(err)
+						return
+					}
 				}
 
-				if err != nil {
-					errChannel <- err
-					return
+				if p.revision != "" {
+					if err := p.writeRevision(systemType, ssh, target); err != nil {
+						fail(err)
+						return
+					}
 				}
+				tr.finish(nil)
 			}
 
 			// remove tar file
-			err = p.removeDestFile(systemType, ssh)
-			if err != nil {
-				errChannel <- err
-				return
+			hr.begin("cleanup")
+			if !p.Config.Incremental {
+				err = p.removeDestFile(systemType, ssh)
+				if err != nil {
+					fail(err)
+					return
+				}
 			}
-		}(host)
+
+			hr.finish(nil)
+		}(host, rep.Hosts[i])
 	}
 
 	go func() {
@@ -372,19 +669,31 @@ func (p *Plugin) Exec() error {
 
 	select {
 	case <-finished:
-	case err := <-errChannel:
-		if err != nil {
-			c := color.New(color.FgRed)
-			c.Println("drone-scp error: ", err)
-			var cerr copyError
-			if !errors.As(err, &cerr) {
-				fmt.Println("drone-scp rollback: remove all target tmp file")
-				if err := p.removeAllDestFile(); err != nil {
-					return err
-				}
+	case err = <-errChannel:
+	}
+
+	if p.reporting() {
+		// wait for the result of every host
+		<-finished
+		if err == nil {
+			select {
+			case err = <-errChannel:
+			default:
+			}
+		}
+	}
+
+	if err != nil {
+		c := color.New(color.FgRed)
+		c.Println("drone-scp error: ", err)
+		var cerr copyError
+		if !errors.As(err, &cerr) {
+			fmt.Println("drone-scp rollback: remove all target tmp file")
+			if err := p.removeAllDestFile(); err != nil {
+				return err
 			}
-			return err
 		}
+		return err
 	}
 
 	fmt.Println("===================================================")
@@ -394,6 +703,39 @@ func (p *Plugin) Exec() error {
 	return nil
 }
 
+// sshConfig creates the MakeConfig instance with remote username, server address and path to private key.
+func (p Plugin) sshConfig(h string) *easyssh.MakeConfig {
+	host, port := p.hostPort(h)
+	return &easyssh.MakeConfig{
+		Server:            host,
+		User:              p.Config.Username,
+		Password:          p.Config.Password,
+		Port:              port,
+		Protocol:          p.Config.Protocol,
+		Key:               p.Config.Key,
+		KeyPath:           p.Config.KeyPath,
+		Passphrase:        p.Config.Passphrase,
+		Timeout:           p.Config.Timeout,
+		Ciphers:           p.Config.Ciphers,
+		Fingerprint:       p.Config.Fingerprint,
+		UseInsecureCipher: p.Config.UseInsecureCipher,
+		Proxy: easyssh.DefaultConfig{
+			Server:            p.Config.Proxy.Server,
+			User:              p.Config.Proxy.User,
+			Password:          p.Config.Proxy.Password,
+			Port:              p.Config.Proxy.Port,
+			Protocol:          p.Config.Proxy.Protocol,
+			Key:               p.Config.Proxy.Key,
+			KeyPath:           p.Config.Proxy.KeyPath,
+			Passphrase:        p.Config.Proxy.Passphrase,
+			Timeout:           p.Config.Proxy.Timeout,
+			Ciphers:           p.Config.Proxy.Ciphers,
+			Fingerprint:       p.Config.Proxy.Fingerprint,
+			UseInsecureCipher: p.Config.Proxy.UseInsecureCipher,
+		},
+	}
+}
+
 func (p Plugin) hostPort(host string) (string, string) {
 	hosts := strings.Split(host, ":")
 	port := strconv.Itoa(p.Config.Port)
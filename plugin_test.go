@@ -681,6 +681,48 @@ func TestPlugin_buildUnTarArgs(t *testing.T) {
 			},
 			want: []string{"tar", "-zxf", "foo.tar.gz", "--strip-components", "2", "--overwrite", "--unlink-first", "-C", "foo\\ bar"},
 		},
+		{
+			name: "zstd compression",
+			fields: fields{
+				Config: Config{
+					TarExec:     "tar",
+					Compression: "zstd",
+				},
+				DestFile: "foo.tar.zst",
+			},
+			args: args{
+				target: "foo",
+			},
+			want: []string{"tar", "--zstd", "-xf", "foo.tar.zst", "-C", "foo"},
+		},
+		{
+			name: "xz compression",
+			fields: fields{
+				Config: Config{
+					TarExec:     "tar",
+					Compression: "xz",
+				},
+				DestFile: "foo.tar.xz",
+			},
+			args: args{
+				target: "foo",
+			},
+			want: []string{"tar", "-Jxf", "foo.tar.xz", "-C", "foo"},
+		},
+		{
+			name: "without compression",
+			fields: fields{
+				Config: Config{
+					TarExec:     "tar",
+					Compression: "none",
+				},
+				DestFile: "foo.tar",
+			},
+			args: args{
+				target: "foo",
+			},
+			want: []string{"tar", "-xf", "foo.tar", "-C", "foo"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -753,6 +795,46 @@ func TestPlugin_buildTarArgs(t *testing.T) {
 			},
 			want: []string{"--exclude", "tests/a.txt", "--dereference", "-zcf", "foo.tar.gz", "tests/a.txt", "tests/b.txt"},
 		},
+		{
+			name: "bzip2 compression",
+			fields: fields{
+				Config: Config{
+					TarExec:     "tar",
+					Compression: "bzip2",
+				},
+			},
+			args: args{
+				src: "foo.tar.bz2",
+			},
+			want: []string{"-jcf", "foo.tar.bz2"},
+		},
+		{
+			name: "zstd compression",
+			fields: fields{
+				Config: Config{
+					TarExec:     "tar",
+					Compression: "zstd",
+				},
+			},
+			args: args{
+				src: "foo.tar.zst",
+			},
+			want: []string{"--zstd", "-cf", "foo.tar.zst"},
+		},
+		{
+			name: "compression level",
+			fields: fields{
+				Config: Config{
+					TarExec:          "tar",
+					Compression:      "gzip",
+					CompressionLevel: 9,
+				},
+			},
+			args: args{
+				src: "foo.tar.gz",
+			},
+			want: []string{"--use-compress-program", "gzip -9", "-cf", "foo.tar.gz"},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-isatty"
+)
+
+// barInterval is the refresh interval of the interactive progress bar.
+const barInterval = 200 * time.Millisecond
+
+// progress reports the state of a long running phase until it is stopped.
+// All methods are safe to call on a nil progress, which reports nothing.
+type progress struct {
+	plugin *Plugin
+	host   string
+	phase  string
+	// total is zero when the size of the phase is unknown.
+	total int64
+	done  atomic.Int64
+	// sample replaces the counter when the progress is measured elsewhere.
+	sample func() int64
+	start  time.Time
+	bar    bool
+	quit   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// interactive reports whether a progress bar can be drawn, which is only the
+// case for a single host on a terminal.
+func (p *Plugin) interactive() bool {
+	if len(p.Config.Host) > 1 {
+		return false
+	}
+	return isatty.IsTerminal(os.Stdout.Fd()) || isatty.IsCygwinTerminal(os.Stdout.Fd())
+}
+
+// startProgress reports the progress of a phase on the given host, or of a
+// local phase when host is empty. It returns nil when progress is disabled.
+func (p *Plugin) startProgress(host, phase string, total int64) *progress {
+	return p.startSampledProgress(host, phase, total, nil)
+}
+
+func (p *Plugin) startSampledProgress(host, phase string, total int64, sample func() int64) *progress {
+	if p.Config.ProgressInterval <= 0 {
+		return nil
+	}
+
+	pr := &progress{
+		plugin: p,
+		host:   host,
+		phase:  phase,
+		total:  total,
+		sample: sample,
+		start:  time.Now(),
+		bar:    p.interactive(),
+		quit:   make(chan struct{}),
+	}
+
+	interval := p.Config.ProgressInterval
+	if pr.bar {
+		interval = barInterval
+	}
+
+	pr.wg.Add(1)
+	go func() {
+		defer pr.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-pr.quit:
+				return
+			case <-ticker.C:
+				pr.print()
+			}
+		}
+	}()
+	return pr
+}
+
+// Add counts n more bytes.
+func (pr *progress) Add(n int64) {
+	if pr != nil {
+		pr.done.Add(n)
+	}
+}
+
+// Write counts the written bytes, so the progress can be used with io.TeeReader.
+func (pr *progress) Write(b []byte) (int, error) {
+	pr.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Reader counts the bytes read from r.
+func (pr *progress) Reader(r io.Reader) io.Reader {
+	if pr == nil {
+		return r
+	}
+	return io.TeeReader(r, pr)
+}
+
+// Stop ends the reporting and prints the final state.
+func (pr *progress) Stop() {
+	if pr == nil {
+		return
+	}
+	close(pr.quit)
+	pr.wg.Wait()
+	if pr.bar {
+		fmt.Print("\r" + pr.line() + "\n")
+	}
+}
+
+func (pr *progress) current() int64 {
+	if pr.sample != nil {
+		return pr.sample()
+	}
+	return pr.done.Load()
+}
+
+func (pr *progress) print() {
+	if pr.bar {
+		fmt.Print("\r" + pr.line())
+		return
+	}
+	if pr.host == "" {
+		fmt.Println(pr.line())
+		return
+	}
+	pr.plugin.log(pr.host, pr.line())
+}
+
+func (pr *progress) line() string {
+	return pr.format(pr.current(), time.Since(pr.start))
+}
+
+// format returns the phase with bytes done, percent, rate and ETA where known.
+func (pr *progress) format(done int64, elapsed time.Duration) string {
+	if done == 0 && pr.total == 0 {
+		return fmt.Sprintf("%s: %s elapsed", pr.phase, elapsed.Round(time.Second))
+	}
+
+	rate := float64(done) / elapsed.Seconds()
+	if pr.total <= 0 {
+		return fmt.Sprintf("%s: %s, %s/s", pr.phase, formatBytes(done), formatBytes(int64(rate)))
+	}
+
+	percent := min(100*done/pr.total, 100)
+	eta := "unknown"
+	if rate > 0 {
+		eta = time.Duration(float64(max(pr.total-done, 0)) / rate * float64(time.Second)).Round(time.Second).String()
+	}
+
+	if pr.bar {
+		const width = 30
+		filled := int(percent * width / 100)
+		return fmt.Sprintf("%s [%s%s] %3d%% %s/%s %s/s ETA %s ",
+			pr.phase, strings.Repeat("=", filled), strings.Repeat(" ", width-filled),
+			percent, formatBytes(done), formatBytes(pr.total), formatBytes(int64(rate)), eta)
+	}
+	return fmt.Sprintf("%s: %s of %s (%d%%), %s/s, ETA %s",
+		pr.phase, formatBytes(done), formatBytes(pr.total), percent, formatBytes(int64(rate)), eta)
+}
+
+// formatBytes formats a size with binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// fileSize returns the current size of a file, used to follow a growing archive.
+func fileSize(path string) func() int64 {
+	return func() int64 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return 0
+		}
+		return info.Size()
+	}
+}
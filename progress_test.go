@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", formatBytes(0))
+	assert.Equal(t, "1023 B", formatBytes(1023))
+	assert.Equal(t, "1.0 KiB", formatBytes(1024))
+	assert.Equal(t, "1.5 MiB", formatBytes(3<<19))
+	assert.Equal(t, "2.0 GiB", formatBytes(2<<30))
+}
+
+func TestProgressDisabled(t *testing.T) {
+	p := Plugin{}
+	pr := p.startProgress("localhost", "upload", 100)
+	assert.Nil(t, pr)
+
+	// a nil progress passes the data through
+	reader := bytes.NewReader([]byte("hello"))
+	assert.Equal(t, reader, pr.Reader(reader))
+	pr.Add(10)
+	pr.Stop()
+}
+
+func TestProgressFormat(t *testing.T) {
+	pr := &progress{phase: "upload", total: 4 << 20}
+	assert.Equal(t, "upload: 1.0 MiB of 4.0 MiB (25%), 512.0 KiB/s, ETA 6s", pr.format(1<<20, 2*time.Second))
+	assert.Equal(t, "upload: 4.0 MiB of 4.0 MiB (100%), 2.0 MiB/s, ETA 0s", pr.format(4<<20, 2*time.Second))
+
+	pr.bar = true
+	assert.Equal(t, "upload [=======                       ]  25% 1.0 MiB/4.0 MiB 512.0 KiB/s ETA 6s ", pr.format(1<<20, 2*time.Second))
+
+	pr = &progress{phase: "archive"}
+	assert.Equal(t, "archive: 2.0 MiB, 1.0 MiB/s", pr.format(2<<20, 2*time.Second))
+
+	pr = &progress{phase: "extract /var/www"}
+	assert.Equal(t, "extract /var/www: 3s elapsed", pr.format(0, 3*time.Second))
+}
+
+func TestProgressCount(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			ProgressInterval: time.Hour,
+		},
+	}
+
+	pr := p.startProgress("localhost", "upload", 4<<20)
+	pr.Add(1 << 20)
+	_, err := io.Copy(io.Discard, pr.Reader(bytes.NewReader(make([]byte, 1<<20))))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2<<20), pr.current())
+	pr.Stop()
+
+	pr = p.startSampledProgress("", "archive", 0, func() int64 { return 42 })
+	assert.Equal(t, int64(42), pr.current())
+	pr.Stop()
+}
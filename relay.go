@@ -0,0 +1,128 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/appleboy/easyssh-proxy"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+var (
+	errInvalidRelay         = errors.New(`relay must be "proxy" or "first"`)
+	errRelayWithoutProxy    = errors.New("relay proxy requires the proxy host config")
+	errRelayWithIncremental = errors.New("relay and incremental options cannot be used together")
+	errRelayHostKey         = errors.New("host key does not match the fingerprint")
+)
+
+// relayConfig returns the host which receives the archive once and distributes
+// it to all other hosts, or nil when the relay is disabled.
+func (p *Plugin) relayConfig(hosts []string) (*easyssh.MakeConfig, error) {
+	switch p.Config.Relay {
+	case "":
+		return nil, nil
+	case "proxy":
+		if p.Config.Proxy.Server == "" {
+			return nil, errRelayWithoutProxy
+		}
+		return &easyssh.MakeConfig{
+			Server:            p.Config.Proxy.Server,
+			User:              p.Config.Proxy.User,
+			Password:          p.Config.Proxy.Password,
+			Port:              p.Config.Proxy.Port,
+			Protocol:          p.Config.Proxy.Protocol,
+			Key:               p.Config.Proxy.Key,
+			KeyPath:           p.Config.Proxy.KeyPath,
+			Passphrase:        p.Config.Proxy.Passphrase,
+			Timeout:           p.Config.Proxy.Timeout,
+			Ciphers:           p.Config.Proxy.Ciphers,
+			Fingerprint:       p.Config.Proxy.Fingerprint,
+			UseInsecureCipher: p.Config.Proxy.UseInsecureCipher,
+		}, nil
+	case "first":
+		return p.sshConfig(hosts[0]), nil
+	}
+
+	return nil, errInvalidRelay
+}
+
+// relayFile is the location of the archive on the relay host.
+func (p *Plugin) relayFile() string {
+	return p.DestFile + ".relay"
+}
+
+// uploadRelay uploads the archive to the relay host.
+func (p *Plugin) uploadRelay(relay *easyssh.MakeConfig, src string) error {
+	p.log(relay.Server, "scp file to relay server", relay.Server)
+	err := p.retry(relay.Server, "upload", func() error {
+		return p.upload("unix", relay, src, p.relayFile())
+	})
+	if err != nil {
+		return copyError{relay.Server, err.Error()}
+	}
+	return nil
+}
+
+// knownHostsLine fetches the host key, which must match the configured
+// fingerprint, and returns it as a known_hosts entry for the relay host.
+func (p *Plugin) knownHostsLine(cfg *easyssh.MakeConfig) (string, error) {
+	key, _, err := hostKey(cfg)
+	if err != nil {
+		return "", err
+	}
+	if fingerprint := ssh.FingerprintSHA256(key); fingerprint != p.Config.Fingerprint {
+		return "", fmt.Errorf("%w: %s, expected %s", errRelayHostKey, fingerprint, p.Config.Fingerprint)
+	}
+	return knownhosts.Line([]string{knownhosts.Normalize(net.JoinHostPort(cfg.Server, cfg.Port))}, key), nil
+}
+
+// relayCopy copies the archive from the relay host to the given host. The
+// relay only accepts the pinned host key when a fingerprint is configured.
+func (p *Plugin) relayCopy(relay, cfg *easyssh.MakeConfig) error {
+	if relay.Server == cfg.Server && relay.Port == cfg.Port {
+		// the relay is one of the hosts
+		return p.runRelay(relay, cfg, "cp "+p.relayFile()+" "+p.DestFile)
+	}
+
+	knownHosts := ""
+	if p.Config.Fingerprint != "" {
+		line, err := p.knownHostsLine(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", cfg.Server, err)
+		}
+		knownHosts = p.relayFile() + "." + cfg.Server + "-" + cfg.Port + ".known_hosts"
+		if _, errStr, _, err := p.run(relay, "printf '%s\\n' "+shellQuote(line)+" > "+knownHosts); err != nil {
+			return fmt.Errorf("%w %s", err, errStr)
+		}
+		defer func() {
+			if _, _, _, err := p.run(relay, rmcmd("unix", knownHosts)); err != nil {
+				p.log(relay.Server, "remove known hosts file failed:", err)
+			}
+		}()
+	}
+
+	cmd := relaycmd(p.relayFile(), cfg.User, cfg.Server, cfg.Port, p.DestFile, p.Config.RelayKeyPath, knownHosts, p.bandwidth*8/1000)
+	return p.runRelay(relay, cfg, cmd)
+}
+
+// runRelay runs the command copying the archive on the relay host.
+func (p *Plugin) runRelay(relay, cfg *easyssh.MakeConfig, cmd string) error {
+	p.log(cfg.Server, "copy file from relay server", relay.Server)
+	if p.Config.Debug {
+		fmt.Println("$", cmd)
+	}
+	_, errStr, _, err := p.run(relay, cmd)
+	if err != nil {
+		return copyError{cfg.Server, fmt.Sprintf("%s %s", err, errStr)}
+	}
+	return nil
+}
+
+// removeRelayFile removes the archive from the relay host.
+func (p *Plugin) removeRelayFile(relay *easyssh.MakeConfig) error {
+	p.log(relay.Server, "remove relay file", p.relayFile())
+	_, _, _, err := p.run(relay, rmcmd("unix", p.relayFile()))
+	return err
+}
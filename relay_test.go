@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRelayConfig(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Port:     22,
+			Protocol: easyssh.PROTOCOL_TCP,
+			Username: "deploy",
+		},
+	}
+	hosts := []string{"web1:2222", "web2"}
+
+	relay, err := p.relayConfig(hosts)
+	assert.NoError(t, err)
+	assert.Nil(t, relay)
+
+	p.Config.Relay = "first"
+	relay, err = p.relayConfig(hosts)
+	assert.NoError(t, err)
+	assert.Equal(t, "web1", relay.Server)
+	assert.Equal(t, "2222", relay.Port)
+	assert.Equal(t, "deploy", relay.User)
+
+	p.Config.Relay = "proxy"
+	_, err = p.relayConfig(hosts)
+	assert.Equal(t, errRelayWithoutProxy, err)
+
+	p.Config.Proxy = easyssh.DefaultConfig{
+		Server: "bastion",
+		Port:   "22",
+		User:   "jump",
+	}
+	relay, err = p.relayConfig(hosts)
+	assert.NoError(t, err)
+	assert.Equal(t, "bastion", relay.Server)
+	assert.Equal(t, "jump", relay.User)
+
+	p.Config.Relay = "foo"
+	_, err = p.relayConfig(hosts)
+	assert.Equal(t, errInvalidRelay, err)
+}
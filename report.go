@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Result of a host or target in the run report.
+const (
+	statusSuccess = "success"
+	statusFailure = "failure"
+	statusSkipped = "skipped"
+)
+
+type (
+	// report summarizes a run for the steps following the deployment.
+	report struct {
+		Version   string        `json:"version"`
+		Status    string        `json:"status"`
+		StartedAt time.Time     `json:"started_at"`
+		Duration  float64       `json:"duration"`
+		Archive   reportArchive `json:"archive"`
+		Files     int           `json:"files"`
+		Bytes     int64         `json:"bytes"`
+		Hosts     []*hostReport `json:"hosts"`
+
+		start time.Time
+	}
+
+	// reportArchive describes the archive uploaded to every host.
+	reportArchive struct {
+		Name   string `json:"name,omitempty"`
+		Size   int64  `json:"size"`
+		SHA256 string `json:"sha256,omitempty"`
+	}
+
+	// hostReport is owned by the goroutine deploying to the host.
+	hostReport struct {
+		Host      string             `json:"host"`
+		Port      string             `json:"port"`
+		OS        string             `json:"os,omitempty"`
+		Status    string             `json:"status"`
+		Error     string             `json:"error,omitempty"`
+		Bytes     int64              `json:"bytes"`
+		Duration  float64            `json:"duration"`
+		Durations map[string]float64 `json:"durations"`
+		Targets   []*targetReport    `json:"targets"`
+
+		// targets are expected on the host, all targets of the run when empty.
+		targets    []string
+		start      time.Time
+		phase      string
+		phaseStart time.Time
+		phases     map[string]time.Duration
+	}
+
+	// targetReport is the result of a target folder on a host.
+	targetReport struct {
+		Target   string  `json:"target"`
+		Status   string  `json:"status"`
+		Error    string  `json:"error,omitempty"`
+		Stderr   string  `json:"stderr,omitempty"`
+		Duration float64 `json:"duration"`
+
+		// Tampered, Missing and Extra are found by the verify command.
+		Tampered []string `json:"tampered,omitempty"`
+		Missing  []string `json:"missing,omitempty"`
+		Extra    []string `json:"extra,omitempty"`
+
+		start time.Time
+	}
+
+	// transferStats counts the bytes sent to every host.
+	transferStats struct {
+		sync.Mutex
+		bytes map[string]int64
+	}
+)
+
+// reporting reports whether the result of every host is needed after the run.
+func (p *Plugin) reporting() bool {
+	return p.collect || p.Config.ReportFile != "" || p.Config.JUnitFile != "" ||
+		p.Config.GitHubSummary != "" || p.Config.GitHubOutput != "" ||
+		p.Config.CardPath != "" || len(p.Config.Webhooks) > 0
+}
+
+func (p *Plugin) newReport(hosts []string) *report {
+	now := time.Now()
+	rep := &report{
+		Version:   Version,
+		StartedAt: now.UTC(),
+		start:     now,
+	}
+	for _, h := range hosts {
+		host, port := p.hostPort(h)
+		rep.Hosts = append(rep.Hosts, &hostReport{
+			Host:      host,
+			Port:      port,
+			Status:    statusSkipped,
+			Durations: map[string]float64{},
+			targets:   p.Config.Target,
+			phases:    map[string]time.Duration{},
+			start:     now,
+		})
+	}
+	return rep
+}
+
+func seconds(d time.Duration) float64 {
+	return d.Round(time.Millisecond).Seconds()
+}
+
+// begin ends the current phase of the host and starts the next one.
+// Phases which run once per target are added up.
+func (h *hostReport) begin(phase string) {
+	h.end()
+	h.phase = phase
+	h.phaseStart = time.Now()
+}
+
+func (h *hostReport) end() {
+	if h.phase != "" {
+		h.phases[h.phase] += time.Since(h.phaseStart)
+		h.Durations[h.phase] = seconds(h.phases[h.phase])
+		h.phase = ""
+	}
+}
+
+// expected returns the targets the host should have deployed.
+func (h *hostReport) expected(targets []string) []string {
+	if len(h.targets) > 0 {
+		return h.targets
+	}
+	return targets
+}
+
+// target starts the report of a target folder.
+func (h *hostReport) target(target string) *targetReport {
+	t := &targetReport{Target: target, start: time.Now()}
+	h.Targets = append(h.Targets, t)
+	return t
+}
+
+func (t *targetReport) finish(err error) {
+	t.Duration = seconds(time.Since(t.start))
+	t.Status = statusSuccess
+	if err != nil {
+		t.Status = statusFailure
+		t.Error = strings.TrimSpace(err.Error())
+	}
+}
+
+// finish records the result of the host and of a target still in progress.
+func (h *hostReport) finish(err error) {
+	h.end()
+	h.Duration = seconds(time.Since(h.start))
+	h.Status = statusSuccess
+	if err != nil {
+		h.Status = statusFailure
+		h.Error = strings.TrimSpace(err.Error())
+	}
+	if n := len(h.Targets); n > 0 && h.Targets[n-1].Status == "" {
+		h.Targets[n-1].finish(err)
+	}
+}
+
+// finish records the overall result. The archive is only described when it
+// was uploaded as a whole.
+func (r *report) finish(p *Plugin, src string, files []localFile, err error) {
+	r.Duration = seconds(time.Since(r.start))
+	r.Status = statusSuccess
+	if err != nil {
+		r.Status = statusFailure
+	}
+
+	for _, f := range files {
+		if !f.IsDir() {
+			r.Files++
+		}
+	}
+
+	for _, h := range r.Hosts {
+		h.Bytes = p.sent.get(net.JoinHostPort(h.Host, h.Port))
+		r.Bytes += h.Bytes
+	}
+
+	if p.Config.Incremental || src == "" {
+		return
+	}
+	r.Archive.Name = p.DestFile
+	if info, err := os.Stat(src); err == nil {
+		r.Archive.Size = info.Size()
+	}
+	r.Archive.SHA256, _ = fileHash(src)
+}
+
+// mergeReports combines the reports of several runs into one. Hosts and
+// targets found in several reports are merged, a failure wins over a success.
+// The archive is only described when a single run uploaded one.
+func mergeReports(reports []*report) *report {
+	if len(reports) == 1 {
+		return reports[0]
+	}
+
+	merged := &report{Version: Version, Status: statusSuccess}
+	hosts := map[string]*hostReport{}
+	archives := 0
+	for _, r := range reports {
+		if merged.start.IsZero() || r.start.Before(merged.start) {
+			merged.start, merged.StartedAt = r.start, r.StartedAt
+		}
+		if r.Status == statusFailure {
+			merged.Status = statusFailure
+		}
+		merged.Files += r.Files
+		merged.Bytes += r.Bytes
+		merged.Archive.Size += r.Archive.Size
+		if r.Archive.Name != "" {
+			archives++
+			merged.Archive.Name, merged.Archive.SHA256 = r.Archive.Name, r.Archive.SHA256
+		}
+
+		for _, h := range r.Hosts {
+			key := net.JoinHostPort(h.Host, h.Port)
+			m, ok := hosts[key]
+			if !ok {
+				m = &hostReport{Host: h.Host, Port: h.Port, Status: statusSkipped, Durations: map[string]float64{}}
+				hosts[key] = m
+				merged.Hosts = append(merged.Hosts, m)
+			}
+			m.merge(h)
+		}
+	}
+	if archives != 1 {
+		merged.Archive.Name, merged.Archive.SHA256 = "", ""
+	}
+	merged.Duration = seconds(time.Since(merged.start))
+	return merged
+}
+
+// targets lists the targets expected on the hosts of the report.
+func (r *report) targets() []string {
+	var targets []string
+	for _, h := range r.Hosts {
+		for _, target := range h.targets {
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// merge adds the result of another run on the same host.
+func (h *hostReport) merge(other *hostReport) {
+	if h.OS == "" {
+		h.OS = other.OS
+	}
+	switch {
+	case other.Status == statusFailure && h.Status != statusFailure:
+		h.Status, h.Error = statusFailure, other.Error
+	case other.Status == statusSuccess && h.Status == statusSkipped:
+		h.Status = statusSuccess
+	}
+	h.Bytes += other.Bytes
+	h.Duration += other.Duration
+	for phase, d := range other.Durations {
+		h.Durations[phase] += d
+	}
+	for _, target := range other.targets {
+		if !slices.Contains(h.targets, target) {
+			h.targets = append(h.targets, target)
+		}
+	}
+
+	for _, t := range other.Targets {
+		var found *targetReport
+		for _, v := range h.Targets {
+			if v.Target == t.Target {
+				found = v
+				break
+			}
+		}
+		if found == nil {
+			copied := *t
+			h.Targets = append(h.Targets, &copied)
+			continue
+		}
+		found.Duration += t.Duration
+		if t.Status == statusFailure && found.Status != statusFailure {
+			found.Status, found.Error, found.Stderr = statusFailure, t.Error, t.Stderr
+		}
+	}
+}
+
+func (r *report) write(file string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+// writeReports writes every requested report.
+func (p *Plugin) writeReports(r *report) error {
+	if p.Config.ReportFile != "" {
+		if err := r.write(p.Config.ReportFile); err != nil {
+			return err
+		}
+	}
+	if p.Config.JUnitFile != "" {
+		if err := r.writeJUnit(p.Config.JUnitFile, p.Config.Target); err != nil {
+			return err
+		}
+	}
+	if p.Config.CardPath != "" {
+		if err := r.writeCard(p.Config.CardPath, p.Config.Target); err != nil {
+			return err
+		}
+	}
+	return r.writeGitHub(p.Config.GitHubSummary, p.Config.GitHubOutput, p.Config.Target)
+}
+
+func (s *transferStats) add(host string, n int64) {
+	if s == nil {
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	if s.bytes == nil {
+		s.bytes = map[string]int64{}
+	}
+	s.bytes[host] += n
+}
+
+func (s *transferStats) get(host string) int64 {
+	if s == nil {
+		return 0
+	}
+	s.Lock()
+	defer s.Unlock()
+	return s.bytes[host]
+}
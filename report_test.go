@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHostReport(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Port:     22,
+			Protocol: easyssh.PROTOCOL_TCP,
+		},
+	}
+	rep := p.newReport([]string{"example.com", "10.0.0.2:2222"})
+	assert.Len(t, rep.Hosts, 2)
+	assert.Equal(t, "example.com", rep.Hosts[0].Host)
+	assert.Equal(t, "22", rep.Hosts[0].Port)
+	assert.Equal(t, "2222", rep.Hosts[1].Port)
+	assert.Equal(t, statusSkipped, rep.Hosts[1].Status)
+
+	h := rep.Hosts[0]
+	h.begin("connect")
+	h.begin("mkdir")
+	h.target("/var/www").finish(nil)
+	h.target("/var/backup")
+	h.begin("mkdir")
+	h.finish(errors.New("permission denied"))
+
+	assert.Equal(t, statusFailure, h.Status)
+	assert.Equal(t, "permission denied", h.Error)
+	assert.Contains(t, h.Durations, "connect")
+	assert.Contains(t, h.Durations, "mkdir")
+	assert.NotContains(t, h.Durations, "untar")
+	assert.Equal(t, statusSuccess, h.Targets[0].Status)
+	assert.Equal(t, statusFailure, h.Targets[1].Status)
+	assert.Equal(t, "permission denied", h.Targets[1].Error)
+}
+
+func TestReportFile(t *testing.T) {
+	dir := t.TempDir()
+	p := Plugin{
+		Config: Config{
+			Port:       22,
+			ReportFile: filepath.Join(dir, "report.json"),
+		},
+		DestFile: "/tmp/a.tar.gz",
+		sent:     &transferStats{},
+	}
+	assert.True(t, p.reporting())
+
+	rep := p.newReport([]string{"example.com"})
+	rep.Hosts[0].finish(nil)
+	p.sent.add(net.JoinHostPort("example.com", "22"), 100)
+	p.sent.add(net.JoinHostPort("example.com", "22"), 20)
+	p.sent.add(net.JoinHostPort("example.com", "2222"), 5)
+
+	files, err := walkFiles(globList([]string{"tests/a.txt", "tests/global"}), false)
+	assert.NoError(t, err)
+	rep.finish(&p, "tests/a.txt", files, nil)
+	assert.NoError(t, p.writeReports(rep))
+
+	data, err := os.ReadFile(p.Config.ReportFile)
+	assert.NoError(t, err)
+
+	var result map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &result))
+	assert.Equal(t, statusSuccess, result["status"])
+	assert.Equal(t, float64(120), result["bytes"])
+	assert.Equal(t, float64(4), result["files"])
+
+	sum, err := fileHash("tests/a.txt")
+	assert.NoError(t, err)
+	archive := result["archive"].(map[string]interface{})
+	assert.Equal(t, "/tmp/a.tar.gz", archive["name"])
+	assert.Equal(t, sum, archive["sha256"])
+
+	host := result["hosts"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "example.com", host["host"])
+	assert.Equal(t, statusSuccess, host["status"])
+	assert.Equal(t, float64(120), host["bytes"])
+}
+
+func TestMergeReports(t *testing.T) {
+	web := Plugin{Config: Config{Port: 22, Target: []string{"/var/www"}}}
+	api := Plugin{Config: Config{Port: 22, Target: []string{"/srv/api"}}}
+
+	first := web.newReport([]string{"example.com"})
+	first.Files, first.Archive = 2, reportArchive{Name: "/tmp/a.tar.gz", Size: 10, SHA256: "aaa"}
+	first.Hosts[0].target("/var/www").finish(nil)
+	first.Hosts[0].finish(nil)
+
+	second := api.newReport([]string{"example.com", "example.org"})
+	second.Status, second.Files = statusFailure, 3
+	second.Archive = reportArchive{Name: "/tmp/b.tar.gz", Size: 20, SHA256: "bbb"}
+	second.Hosts[0].target("/srv/api")
+	second.Hosts[0].finish(errors.New("permission denied"))
+
+	rep := mergeReports([]*report{first, second})
+	assert.Equal(t, statusFailure, rep.Status)
+	assert.Equal(t, 5, rep.Files)
+	assert.Equal(t, reportArchive{Size: 30}, rep.Archive)
+	assert.Len(t, rep.Hosts, 2)
+
+	h := rep.Hosts[0]
+	assert.Equal(t, statusFailure, h.Status)
+	assert.Equal(t, "permission denied", h.Error)
+	assert.Len(t, h.Targets, 2)
+	assert.Equal(t, []string{"/var/www", "/srv/api"}, h.expected(nil))
+	assert.Equal(t, statusSkipped, rep.Hosts[1].Status)
+	assert.Equal(t, []string{"/srv/api"}, rep.Hosts[1].expected(nil))
+
+	// the skipped host only lists its own target
+	assert.Equal(t, 3, rep.junit([]string{"/var/www", "/srv/api"}).Tests)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"golang.org/x/crypto/ssh"
+)
+
+var errInvalidRetryClass = errors.New("retry.on supports connection, timeout and auth")
+
+// retryClasses maps every retryable error class to the messages identifying it.
+var retryClasses = map[string][]string{
+	"connection": {
+		"connection reset",
+		"connection refused",
+		"broken pipe",
+		"no route to host",
+		"network is unreachable",
+		"unexpected eof",
+	},
+	"timeout": {
+		"i/o timeout",
+		"handshake timeout",
+		"timed out",
+		"deadline exceeded",
+	},
+	"auth": {
+		"unable to authenticate",
+	},
+}
+
+// defaultRetryClasses are used when no class is configured.
+var defaultRetryClasses = []string{"connection", "timeout"}
+
+func (p *Plugin) retryClasses() ([]string, error) {
+	classes := trimValues(p.Config.RetryOn)
+	if len(classes) == 0 {
+		return defaultRetryClasses, nil
+	}
+	for _, class := range classes {
+		if _, ok := retryClasses[class]; !ok {
+			return nil, errInvalidRetryClass
+		}
+	}
+	return classes, nil
+}
+
+// retryable reports whether err belongs to one of the configured error classes.
+// A command exiting with a non-zero status is never retried.
+func (p *Plugin) retryable(err error) bool {
+	var exitErr *ssh.ExitError
+	if err == nil || errors.As(err, &exitErr) {
+		return false
+	}
+
+	classes, _ := p.retryClasses()
+	for _, class := range classes {
+		if class == "connection" && errors.Is(err, io.EOF) {
+			return true
+		}
+		var netErr net.Error
+		if class == "timeout" && errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+
+		message := strings.ToLower(err.Error())
+		for _, v := range retryClasses[class] {
+			if strings.Contains(message, v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry, doubling the initial
+// backoff every attempt up to the configured maximum. A maximum of zero
+// doesn't limit the delay.
+func (p *Plugin) backoff(attempt int) time.Duration {
+	delay := p.Config.Backoff
+	for i := 0; i < attempt && delay <= math.MaxInt64/2; i++ {
+		if p.Config.MaxBackoff > 0 && delay >= p.Config.MaxBackoff {
+			break
+		}
+		delay *= 2
+	}
+	if p.Config.MaxBackoff > 0 && delay > p.Config.MaxBackoff {
+		delay = p.Config.MaxBackoff
+	}
+	return delay
+}
+
+// retry calls fn until it succeeds, fails with an error which is not
+// retryable or the configured number of retries is used up.
+func (p *Plugin) retry(host, operation string, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.Config.Retries || !p.retryable(err) {
+			return err
+		}
+
+		delay := p.backoff(attempt)
+		p.log(host, fmt.Sprintf("%s failed (attempt %d/%d): %v, retry in %s",
+			operation, attempt+1, p.Config.Retries+1, err, delay))
+		time.Sleep(delay)
+	}
+}
+
+// run executes a remote command, retrying on transient errors. The command
+// may already have run when the connection drops, so it must be idempotent.
+func (p *Plugin) run(ssh *easyssh.MakeConfig, command string) (outStr, errStr string, isTimeout bool, err error) {
+	err = p.retry(ssh.Server, "run "+command, func() error {
+		var err error
+		outStr, errStr, isTimeout, err = ssh.Run(command, p.Config.CommandTimeout)
+		return err
+	})
+	return outStr, errStr, isTimeout, err
+}
+
+// systemType connects to the host and detects the remote operating system.
+// The "ver" command only exists on windows, so failing with an exit status
+// means a unix host, while any other error is a connection problem.
+func (p *Plugin) systemType(ssh *easyssh.MakeConfig) string {
+	systemType := "unix"
+	_ = p.retry(ssh.Server, "connect", func() error {
+		_, _, _, err := ssh.Run("ver", p.Config.CommandTimeout)
+		if err == nil {
+			systemType = "windows"
+		}
+		if p.retryable(err) {
+			return err
+		}
+		return nil
+	})
+	return systemType
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestRetryable(t *testing.T) {
+	p := Plugin{}
+
+	assert.False(t, p.retryable(nil))
+	assert.True(t, p.retryable(errors.New("dial tcp 10.0.0.1:22: connect: connection refused")))
+	assert.True(t, p.retryable(fmt.Errorf("read: %w", io.EOF)))
+	assert.True(t, p.retryable(errors.New("dial tcp 10.0.0.1:22: i/o timeout")))
+	assert.False(t, p.retryable(errors.New("ssh: unable to authenticate, attempted methods [none password]")))
+	assert.False(t, p.retryable(&ssh.ExitError{}))
+	assert.False(t, p.retryable(errors.New("permission denied")))
+
+	p.Config.RetryOn = []string{"auth"}
+	assert.True(t, p.retryable(errors.New("ssh: unable to authenticate, attempted methods [none password]")))
+	assert.False(t, p.retryable(errors.New("connection refused")))
+}
+
+func TestRetryClasses(t *testing.T) {
+	p := Plugin{}
+	classes, err := p.retryClasses()
+	assert.NoError(t, err)
+	assert.Equal(t, defaultRetryClasses, classes)
+
+	p.Config.RetryOn = []string{"timeout", " auth "}
+	classes, err = p.retryClasses()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"timeout", "auth"}, classes)
+
+	p.Config.RetryOn = []string{"disk"}
+	_, err = p.retryClasses()
+	assert.Equal(t, errInvalidRetryClass, err)
+}
+
+func TestBackoff(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Backoff:    time.Second,
+			MaxBackoff: 5 * time.Second,
+		},
+	}
+
+	assert.Equal(t, time.Second, p.backoff(0))
+	assert.Equal(t, 2*time.Second, p.backoff(1))
+	assert.Equal(t, 4*time.Second, p.backoff(2))
+	assert.Equal(t, 5*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(10))
+
+	// no maximum keeps doubling the delay
+	p.Config.MaxBackoff = 0
+	assert.Equal(t, time.Second, p.backoff(0))
+	assert.Equal(t, 8*time.Second, p.backoff(3))
+	assert.Equal(t, 1024*time.Second, p.backoff(10))
+	assert.Positive(t, p.backoff(100))
+}
+
+func TestRetry(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			Retries:    2,
+			Backoff:    time.Millisecond,
+			MaxBackoff: time.Millisecond,
+		},
+	}
+
+	calls := 0
+	err := p.retry("localhost", "upload", func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("connection reset by peer")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, calls)
+
+	// the retries are used up
+	calls = 0
+	err = p.retry("localhost", "upload", func() error {
+		calls++
+		return errors.New("connection reset by peer")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+
+	// not retryable
+	calls = 0
+	err = p.retry("localhost", "upload", func() error {
+		calls++
+		return errors.New("permission denied")
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+)
+
+var (
+	errSyncWithRemove    = errors.New("sync and rm options cannot be used together")
+	errSyncUnsupportedOS = errors.New("sync mode is not supported on windows host")
+)
+
+// batchSize limits how many files are passed to a single remote command.
+const batchSize = 100
+
+// remoteFile is an entry found below a target folder on the remote host.
+type remoteFile struct {
+	Name    string
+	Dir     bool
+	Size    int64
+	ModTime int64
+}
+
+// syncResult holds the differences between the source list and a target folder.
+type syncResult struct {
+	Added   []string
+	Changed []string
+	Removed []string
+}
+
+// parseRemoteList parses the output of listcmd.
+func parseRemoteList(out string) map[string]remoteFile {
+	files := map[string]remoteFile{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.SplitN(line, "\t", 4)
+		if len(fields) != 4 || fields[3] == "" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[1], 10, 64)
+		mtime, _ := strconv.ParseFloat(fields[2], 64)
+		files[fields[3]] = remoteFile{
+			Name:    fields[3],
+			Dir:     fields[0] == "d",
+			Size:    size,
+			ModTime: int64(mtime),
+		}
+	}
+	return files
+}
+
+// isProtected reports whether name or one of its parent folders matches an exclude pattern.
+func isProtected(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		for p := name; p != "." && p != ""; p = path.Dir(p) {
+			if ok, _ := path.Match(pattern, p); ok {
+				return true
+			}
+			if ok, _ := path.Match(pattern, path.Base(p)); ok && !strings.Contains(pattern, "/") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncPlan compares the local entries, already named relative to the target,
+// with the remote listing. Entries missing from the source are removed unless
+// protected by the exclude patterns or holding a protected entry.
+func syncPlan(local []localFile, remote map[string]remoteFile, excludes []string) syncResult {
+	var result syncResult
+	source := map[string]bool{}
+
+	for _, f := range local {
+		source[f.Name] = true
+		r, ok := remote[f.Name]
+		switch {
+		case !ok:
+			result.Added = append(result.Added, f.Name)
+		case !f.IsDir() && (r.Size != f.Size || r.ModTime != f.ModTime.Unix()):
+			result.Changed = append(result.Changed, f.Name)
+		}
+	}
+
+	keep := map[string]bool{}
+	var candidates []string
+	for name := range remote {
+		if source[name] || isProtected(name, excludes) {
+			for p := name; p != "."; p = path.Dir(p) {
+				keep[p] = true
+			}
+			continue
+		}
+		candidates = append(candidates, name)
+	}
+	sort.Strings(candidates)
+
+	removed := map[string]bool{}
+	for _, name := range candidates {
+		if keep[name] {
+			continue
+		}
+		removed[name] = true
+		// removing the parent folder already takes care of this entry
+		if !removed[path.Dir(name)] {
+			result.Removed = append(result.Removed, name)
+		}
+	}
+
+	sort.Strings(result.Added)
+	sort.Strings(result.Changed)
+	return result
+}
+
+// relativeFiles renames the local entries to the paths they get below a target folder.
+func (p *Plugin) relativeFiles(files []localFile) []localFile {
+	list := make([]localFile, 0, len(files))
+	for _, f := range files {
+		name := f.Name
+		if p.rename != nil && name == p.rename.from {
+			name = p.rename.to
+		}
+		name = stripComponents(name, p.Config.StripComponents)
+		name = strings.TrimPrefix(path.Clean(name), "./")
+		if name == "" || name == "." {
+			continue
+		}
+		f.Name = name
+		list = append(list, f)
+	}
+	return list
+}
+
+func (p *Plugin) listRemoteFiles(systemType string, ssh *easyssh.MakeConfig, target string) (map[string]remoteFile, error) {
+	if systemType == "windows" {
+		return nil, errSyncUnsupportedOS
+	}
+
+	outStr, errStr, _, err := p.run(ssh, listcmd(systemType, target))
+	if err != nil {
+		return nil, err
+	}
+	if errStr != "" {
+		return nil, errors.New(errStr)
+	}
+
+	return parseRemoteList(outStr), nil
+}
+
+// removeRemoteFiles deletes the given entries below the target folder.
+func (p *Plugin) removeRemoteFiles(systemType string, ssh *easyssh.MakeConfig, target string, files []string) error {
+	for start := 0; start < len(files); start += batchSize {
+		end := min(start+batchSize, len(files))
+		cmd := rmfilescmd(systemType, target, files[start:end])
+		if p.Config.Debug {
+			fmt.Println("$", cmd)
+		}
+		_, errStr, _, err := p.run(ssh, cmd)
+		if err != nil {
+			return err
+		}
+		if errStr != "" {
+			return errors.New(errStr)
+		}
+	}
+	return nil
+}
+
+// syncTarget removes the files which no longer exist in the source and
+// reports what was added, changed and removed.
+func (p *Plugin) syncTarget(systemType string, ssh *easyssh.MakeConfig, target string, local []localFile, remote map[string]remoteFile) error {
+	// never delete the manifest written in incremental mode
+	excludes := append([]string{manifestName, revisionName}, p.Config.SyncExclude...)
+	result := syncPlan(local, remote, excludes)
+
+	if err := p.removeRemoteFiles(systemType, ssh, target, result.Removed); err != nil {
+		return err
+	}
+
+	p.log(ssh.Server, fmt.Sprintf("sync %s: %d added, %d changed, %d removed",
+		target, len(result.Added), len(result.Changed), len(result.Removed)))
+	if p.Config.Debug {
+		for _, name := range result.Added {
+			p.log(ssh.Server, "+", name)
+		}
+		for _, name := range result.Changed {
+			p.log(ssh.Server, "~", name)
+		}
+		for _, name := range result.Removed {
+			p.log(ssh.Server, "-", name)
+		}
+	}
+
+	return nil
+}
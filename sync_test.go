@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRemoteList(t *testing.T) {
+	out := "d\t4096\t1700000000.1234\tassets\n" +
+		"f\t12\t1700000001.9\tassets/app.js\n" +
+		"f\t5\t1700000002.0\tindex with space.html\n" +
+		"\n"
+
+	files := parseRemoteList(out)
+	assert.Len(t, files, 3)
+	assert.True(t, files["assets"].Dir)
+	assert.Equal(t, int64(12), files["assets/app.js"].Size)
+	assert.Equal(t, int64(1700000001), files["assets/app.js"].ModTime)
+	assert.Equal(t, int64(5), files["index with space.html"].Size)
+}
+
+func TestIsProtected(t *testing.T) {
+	patterns := []string{"uploads", "config/*.env", "*.log"}
+
+	assert.True(t, isProtected("uploads", patterns))
+	assert.True(t, isProtected("uploads/2024/a.jpg", patterns))
+	assert.True(t, isProtected("config/prod.env", patterns))
+	assert.True(t, isProtected("logs/app.log", patterns))
+	assert.False(t, isProtected("config/app.yml", patterns))
+	assert.False(t, isProtected("index.html", patterns))
+}
+
+func TestSyncPlan(t *testing.T) {
+	mtime := time.Unix(1700000000, 0)
+	local := []localFile{
+		{Name: "assets", Mode: os.ModeDir | 0o755},
+		{Name: "assets/app.js", Size: 12, ModTime: mtime},
+		{Name: "index.html", Size: 20, ModTime: mtime},
+		{Name: "about.html", Size: 8, ModTime: mtime},
+	}
+	remote := map[string]remoteFile{
+		"assets":            {Name: "assets", Dir: true},
+		"assets/app.js":     {Name: "assets/app.js", Size: 12, ModTime: 1700000000},
+		"assets/old.js":     {Name: "assets/old.js", Size: 3, ModTime: 1600000000},
+		"index.html":        {Name: "index.html", Size: 18, ModTime: 1600000000},
+		"legacy":            {Name: "legacy", Dir: true},
+		"legacy/a.html":     {Name: "legacy/a.html"},
+		"legacy/b.html":     {Name: "legacy/b.html"},
+		"uploads":           {Name: "uploads", Dir: true},
+		"uploads/photo.jpg": {Name: "uploads/photo.jpg"},
+		"cache":             {Name: "cache", Dir: true},
+		"cache/keep.txt":    {Name: "cache/keep.txt"},
+		"cache/tmp.txt":     {Name: "cache/tmp.txt"},
+	}
+
+	result := syncPlan(local, remote, []string{"uploads", "cache/keep.txt"})
+	assert.Equal(t, []string{"about.html"}, result.Added)
+	assert.Equal(t, []string{"index.html"}, result.Changed)
+	assert.Equal(t, []string{"assets/old.js", "cache/tmp.txt", "legacy"}, result.Removed)
+}
+
+func TestRelativeFiles(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			StripComponents: 1,
+		},
+	}
+
+	files := p.relativeFiles([]localFile{
+		{Name: "dist"},
+		{Name: "dist/index.html"},
+		{Name: "./dist/app.js"},
+	})
+
+	assert.Len(t, files, 2)
+	assert.Equal(t, "index.html", files[0].Name)
+	assert.Equal(t, "dist/app.js", files[1].Name)
+}
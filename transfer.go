@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+)
+
+// defaultChunkSize is the size of a single chunk of a resumable upload.
+const defaultChunkSize = 8 << 20
+
+// lockStaleMinutes is the age of a lock left behind by a run which died.
+const lockStaleMinutes = 10
+
+// upload copies the local file src to dest on the remote host.
+func (p *Plugin) upload(systemType string, ssh *easyssh.MakeConfig, src, dest string) error {
+	if p.Config.Resume && systemType == "unix" {
+		return p.resumableUpload(systemType, ssh, src, dest)
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	pr := p.startProgress(ssh.Server, "upload", info.Size())
+	defer pr.Stop()
+	if err := ssh.WriteFile(p.throttle(pr.Reader(f)), info.Size(), dest); err != nil {
+		return err
+	}
+	p.sent.add(net.JoinHostPort(ssh.Server, ssh.Port), info.Size())
+	return nil
+}
+
+// partName returns the name of the partial upload of an archive in resumable
+// mode, which is derived from its checksum so another run can resume it.
+func (p *Plugin) partName(src string) (string, error) {
+	build := func() (string, error) {
+		sum, err := fileHash(src)
+		if err != nil {
+			return "", err
+		}
+		return p.Config.TarTmpPath + "drone-scp-" + sum[:16] + ".part", nil
+	}
+	if p.parts == nil {
+		return build()
+	}
+	return p.parts.get(src, build)
+}
+
+// prefixHash returns the SHA-256 of the first size bytes of a local file.
+func prefixHash(path string, size int64) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.CopyN(h, f, size); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// remoteSize returns the size of a remote file, 0 if it does not exist.
+func (p *Plugin) remoteSize(systemType string, ssh *easyssh.MakeConfig, file string) (int64, error) {
+	outStr, errStr, _, err := p.run(ssh, sizecmd(systemType, file))
+	if err != nil {
+		return 0, fmt.Errorf("%w %s", err, errStr)
+	}
+	return strconv.ParseInt(strings.TrimSpace(outStr), 10, 64)
+}
+
+// resumeOffset returns the verified size of a previous partial upload.
+func (p *Plugin) resumeOffset(systemType string, ssh *easyssh.MakeConfig, src, part string, size int64) (int64, error) {
+	offset, err := p.remoteSize(systemType, ssh, part)
+	if err != nil || offset == 0 {
+		return 0, err
+	}
+
+	if offset <= size {
+		expected, err := prefixHash(src, offset)
+		if err != nil {
+			return 0, err
+		}
+		outStr, _, _, err := p.run(ssh, "head -c "+strconv.FormatInt(offset, 10)+" "+part+" | sha256sum")
+		if err == nil && strings.HasPrefix(strings.TrimSpace(outStr), expected) {
+			p.log(ssh.Server, fmt.Sprintf("resume upload at %d of %d bytes", offset, size))
+			return offset, nil
+		}
+	}
+
+	p.log(ssh.Server, "discard partial upload", part)
+	if _, _, _, err := p.run(ssh, ": > "+part); err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// resumableUpload appends the file in chunks to a partial file on the remote
+// host and checks the remote size after every chunk. An interrupted upload is
+// resumed from the last verified offset by the next run.
+func (p *Plugin) resumableUpload(systemType string, ssh *easyssh.MakeConfig, src, dest string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	part, err := p.partName(src)
+	if err != nil {
+		return err
+	}
+
+	// the lock keeps the runs uploading the same archive at once apart, the
+	// destination is unique to the run and taken as the owner
+	lock := part + ".lock"
+	if _, _, _, err := p.run(ssh, lockcmd(lock, dest, lockStaleMinutes)); err != nil {
+		p.log(ssh.Server, "partial upload", part, "is used by another run, upload without resume")
+		part, lock = dest+".part", ""
+	} else {
+		defer func() {
+			if _, _, _, err := p.run(ssh, rmcmd(systemType, lock)); err != nil {
+				p.log(ssh.Server, "remove lock failed:", err)
+			}
+		}()
+	}
+
+	offset, err := p.resumeOffset(systemType, ssh, src, part, size)
+	if err != nil {
+		return err
+	}
+
+	pr := p.startProgress(ssh.Server, "upload", size)
+	defer pr.Stop()
+	pr.Add(offset)
+
+	chunkSize := p.Config.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	_, client, err := ssh.Connect()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	for offset < size {
+		n := min(chunkSize, size-offset)
+		session, err := client.NewSession()
+		if err != nil {
+			return err
+		}
+		session.Stdin = p.throttle(pr.Reader(io.NewSectionReader(f, offset, n)))
+		cmd := "cat >> " + part + " && wc -c < " + part
+		if lock != "" {
+			// a lock which is still touched is never taken over
+			cmd = "cat >> " + part + " && touch " + lock + " && wc -c < " + part
+		}
+		out, err := session.Output(cmd)
+		session.Close()
+		if err != nil {
+			return err
+		}
+
+		// only trust what really arrived on the remote host
+		remote, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+		if err != nil {
+			return err
+		}
+		if remote != offset+n {
+			return errors.New("unexpected size of partial upload " + part)
+		}
+		offset = remote
+		p.sent.add(net.JoinHostPort(ssh.Server, ssh.Port), n)
+		if p.Config.Debug {
+			p.log(ssh.Server, fmt.Sprintf("uploaded %d of %d bytes", offset, size))
+		}
+	}
+
+	_, errStr, _, err := p.run(ssh, mvcmd(part, dest, size))
+	if err != nil {
+		return fmt.Errorf("%w %s", err, errStr)
+	}
+	return nil
+}
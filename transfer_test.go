@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartName(t *testing.T) {
+	p := Plugin{
+		Config: Config{
+			TarTmpPath: "/tmp/",
+		},
+	}
+
+	sum, err := fileHash("tests/a.txt")
+	assert.NoError(t, err)
+
+	name, err := p.partName("tests/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/drone-scp-"+sum[:16]+".part", name)
+
+	_, err = p.partName("tests/not-found")
+	assert.Error(t, err)
+
+	// the name is computed once per archive
+	p.parts = &onceCache{}
+	name, err = p.partName("tests/a.txt")
+	assert.NoError(t, err)
+	assert.Equal(t, "/tmp/drone-scp-"+sum[:16]+".part", name)
+	assert.Len(t, p.parts.values, 1)
+}
+
+func TestPrefixHash(t *testing.T) {
+	full, err := fileHash("tests/a.txt")
+	assert.NoError(t, err)
+
+	info, err := walkFiles(globList([]string{"tests/a.txt"}), false)
+	assert.NoError(t, err)
+
+	sum, err := prefixHash("tests/a.txt", info[0].Size)
+	assert.NoError(t, err)
+	assert.Equal(t, full, sum)
+
+	// sha256 of an empty input
+	sum, err = prefixHash("tests/a.txt", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", sum)
+
+	_, err = prefixHash("tests/a.txt", info[0].Size+1)
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/appleboy/easyssh-proxy"
+)
+
+var errVerifyFilesUnsupportedOS = errors.New("verify files is not supported on windows host")
+
+// checksumError is returned when a remote file differs from the local one.
+type checksumError struct {
+	host     string
+	file     string
+	expected string
+	actual   string
+}
+
+func (e checksumError) Error() string {
+	actual := e.actual
+	if actual == "" {
+		actual = "missing file"
+	}
+	return fmt.Sprintf("checksum mismatch on %s: %s, expected sha256 %s, got %s", e.host, e.file, e.expected, actual)
+}
+
+// parseChecksums parses the output of sha256sum into a map of file name to checksum.
+func parseChecksums(out string) map[string]string {
+	sums := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		sum, name, ok := strings.Cut(strings.TrimRight(line, "\r"), " ")
+		if !ok {
+			continue
+		}
+		// sha256sum escapes names containing a backslash or newline
+		if strings.HasPrefix(sum, "\\") {
+			sum = sum[1:]
+			name = strings.NewReplacer(`\\`, `\`, `\n`, "\n").Replace(name)
+		}
+		name = strings.TrimPrefix(strings.TrimPrefix(name, " "), "*")
+		sums[name] = strings.ToLower(sum)
+	}
+	return sums
+}
+
+// verifyArchive compares the checksum of the uploaded archive with the local one.
+func (p *Plugin) verifyArchive(systemType string, ssh *easyssh.MakeConfig, sums *onceCache, src, dest string) error {
+	expected, err := sums.get(src, func() (string, error) {
+		return fileHash(src)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.log(ssh.Server, "verify checksum of", dest)
+	return p.verifyChecksum(systemType, ssh, dest, expected)
+}
+
+// verifyChecksum compares the SHA-256 checksum of a remote file with the expected one.
+func (p *Plugin) verifyChecksum(systemType string, ssh *easyssh.MakeConfig, dest, expected string) error {
+	outStr, errStr, _, err := p.run(ssh, sha256cmd(systemType, dest))
+	if err != nil {
+		return fmt.Errorf("%w %s", err, errStr)
+	}
+
+	actual := strings.ToLower(strings.TrimSpace(outStr))
+	if fields := strings.Fields(actual); len(fields) > 0 {
+		actual = fields[0]
+	}
+	if actual != expected {
+		return checksumError{ssh.Server, dest, expected, actual}
+	}
+	return nil
+}
+
+// verifyFiles compares the checksum of every extracted file with the manifest.
+func (p *Plugin) verifyFiles(systemType string, ssh *easyssh.MakeConfig, target string, m manifest) error {
+	if systemType == "windows" {
+		return errVerifyFilesUnsupportedOS
+	}
+
+	p.log(ssh.Server, "verify extracted files in", target)
+	var names []string
+	for _, f := range m.Files {
+		if f.Mode.IsRegular() {
+			names = append(names, f.Path)
+		}
+	}
+
+	sums, err := p.remoteChecksums(systemType, ssh, target, names)
+	if err != nil {
+		return err
+	}
+	for _, f := range m.Files {
+		if f.Mode.IsRegular() && sums[f.Path] != f.SHA256 {
+			return checksumError{ssh.Server, target + "/" + f.Path, f.SHA256, sums[f.Path]}
+		}
+	}
+
+	return nil
+}
+
+// remoteChecksums returns the checksums of the given files below the target.
+// Missing files are left out of the result.
+func (p *Plugin) remoteChecksums(systemType string, ssh *easyssh.MakeConfig, target string, names []string) (map[string]string, error) {
+	sums := map[string]string{}
+	for start := 0; start < len(names); start += batchSize {
+		batch := names[start:min(start+batchSize, len(names))]
+
+		// sha256sum fails on missing files but still prints the others
+		outStr, errStr, _, err := p.run(ssh, sha256filescmd(systemType, target, batch))
+		if err != nil && outStr == "" {
+			return nil, fmt.Errorf("%w %s", err, errStr)
+		}
+		for name, sum := range parseChecksums(outStr) {
+			sums[name] = sum
+		}
+	}
+	return sums, nil
+}
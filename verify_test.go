@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChecksums(t *testing.T) {
+	out := "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7  index.html\n" +
+		"0263829989B6FD954F72BAAF2FC64BC2E2F01D692D4DE72986EA808F6E99813F *css/app.css\n" +
+		"\\a3a5e715f0cc574a73c3f9bebb6bc24f32ffd5b67b387244c2c909da779a1478  odd\\\\name\n" +
+		"sha256sum: missing.txt: No such file or directory\n"
+
+	sums := parseChecksums(out)
+	assert.Equal(t, "87428fc522803d31065e7bce3cf03fe475096631e5e07bbd7a0fde60c4cf25c7", sums["index.html"])
+	assert.Equal(t, "0263829989b6fd954f72baaf2fc64bc2e2f01d692d4de72986ea808f6e99813f", sums["css/app.css"])
+	assert.Equal(t, "a3a5e715f0cc574a73c3f9bebb6bc24f32ffd5b67b387244c2c909da779a1478", sums["odd\\name"])
+	assert.Empty(t, sums["missing.txt"])
+}
+
+func TestChecksumError(t *testing.T) {
+	err := checksumError{"web1", "/var/www/index.html", "aaa", "bbb"}
+	assert.Equal(t, "checksum mismatch on web1: /var/www/index.html, expected sha256 aaa, got bbb", err.Error())
+
+	err = checksumError{"web1", "/var/www/index.html", "aaa", ""}
+	assert.Equal(t, "checksum mismatch on web1: /var/www/index.html, expected sha256 aaa, got missing file", err.Error())
+}
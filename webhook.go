@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Events sent to the webhooks.
+const (
+	webhookStart   = "start"
+	webhookSuccess = "success"
+	webhookFailure = "failure"
+)
+
+// webhookSignature is the header holding the HMAC-SHA256 of the payload.
+const webhookSignature = "X-Drone-SCP-Signature"
+
+// webhookTimeout limits a single webhook request.
+const webhookTimeout = 10 * time.Second
+
+// webhookEvent is the data sent to the webhooks and passed to the template.
+type webhookEvent struct {
+	Event   string   `json:"event"`
+	Version string   `json:"version"`
+	Hosts   []string `json:"hosts"`
+	Targets []string `json:"targets"`
+	Error   string   `json:"error,omitempty"`
+	Report  *report  `json:"report,omitempty"`
+}
+
+// webhookTemplate parses the configured payload template, nil means JSON.
+func (p *Plugin) webhookTemplate() (*template.Template, error) {
+	if p.Config.WebhookTemplate == "" {
+		return nil, nil
+	}
+	return template.New("webhook").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			data, err := json.Marshal(v)
+			return string(data), err
+		},
+	}).Parse(p.Config.WebhookTemplate)
+}
+
+func (p *Plugin) webhookPayload(event webhookEvent) ([]byte, error) {
+	tpl, err := p.webhookTemplate()
+	if err != nil {
+		return nil, err
+	}
+	if tpl == nil {
+		return json.Marshal(event)
+	}
+
+	var b bytes.Buffer
+	if err := tpl.Execute(&b, event); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// signPayload returns the signature header value of the payload.
+func signPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (p *Plugin) postWebhook(url, event string, payload []byte) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "drone-scp/"+Version)
+	req.Header.Set("X-Drone-SCP-Event", event)
+	if p.Config.WebhookSecret != "" {
+		req.Header.Set(webhookSignature, signPayload(p.Config.WebhookSecret, payload))
+	}
+
+	client := &http.Client{Timeout: webhookTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// notify posts the event to every webhook. A failing webhook never fails the transfer.
+func (p *Plugin) notify(event webhookEvent) {
+	urls := trimValues(p.Config.Webhooks)
+	if len(urls) == 0 {
+		return
+	}
+
+	event.Version = Version
+	event.Hosts = trimValues(p.Config.Host)
+	event.Targets = p.Config.Target
+
+	payload, err := p.webhookPayload(event)
+	if err != nil {
+		fmt.Println("drone-scp webhook error:", err)
+		return
+	}
+
+	for _, url := range urls {
+		if err := p.postWebhook(url, event.Event, payload); err != nil {
+			fmt.Println("drone-scp webhook error:", strings.TrimSpace(err.Error()))
+		}
+	}
+}
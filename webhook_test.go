@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/appleboy/easyssh-proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func webhookServer(t *testing.T) (*httptest.Server, func() []webhookRequest) {
+	var mu sync.Mutex
+	var requests []webhookRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		assert.NoError(t, err)
+		mu.Lock()
+		requests = append(requests, webhookRequest{r.Header, body})
+		mu.Unlock()
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []webhookRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return requests
+	}
+}
+
+func TestSignPayload(t *testing.T) {
+	assert.Equal(t,
+		"sha256=b613679a0814d9ec772f95d778c35fc5ff1697c493715653c6c712144292c5ad",
+		signPayload("", nil))
+	assert.NotEqual(t, signPayload("a", []byte("{}")), signPayload("b", []byte("{}")))
+}
+
+func TestNotify(t *testing.T) {
+	server, requests := webhookServer(t)
+	p := Plugin{
+		Config: Config{
+			Host:          []string{"example.com"},
+			Target:        []string{"/var/www"},
+			Webhooks:      []string{server.URL},
+			WebhookSecret: "secret",
+		},
+	}
+
+	p.notify(webhookEvent{Event: webhookStart})
+	assert.Len(t, requests(), 1)
+
+	req := requests()[0]
+	assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+	assert.Equal(t, webhookStart, req.header.Get("X-Drone-SCP-Event"))
+	assert.Equal(t, signPayload("secret", req.body), req.header.Get(webhookSignature))
+
+	var event webhookEvent
+	assert.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, webhookStart, event.Event)
+	assert.Equal(t, []string{"example.com"}, event.Hosts)
+	assert.Equal(t, []string{"/var/www"}, event.Targets)
+
+	// templated payload
+	p.Config.WebhookTemplate = `{"text": "deploy {{ .Event }} to {{ json .Hosts }}"}`
+	p.notify(webhookEvent{Event: webhookFailure, Error: "failed"})
+	assert.Len(t, requests(), 2)
+	assert.Equal(t, `{"text": "deploy failure to ["example.com"]"}`, string(requests()[1].body))
+
+	p.Config.WebhookTemplate = "{{ .Missing"
+	_, err := p.webhookTemplate()
+	assert.Error(t, err)
+}
+
+func TestExecWebhook(t *testing.T) {
+	server, requests := webhookServer(t)
+	p := Plugin{
+		Config: Config{
+			Host:           []string{"127.0.0.1:1"},
+			Port:           22,
+			Protocol:       easyssh.PROTOCOL_TCP,
+			Username:       "drone-scp",
+			Password:       "1234",
+			Timeout:        time.Second,
+			CommandTimeout: time.Second,
+			Source:         []string{"tests/a.txt"},
+			Target:         []string{"/tmp/drone-scp-webhook"},
+			TarExec:        "tar",
+			Webhooks:       []string{server.URL},
+		},
+	}
+
+	assert.Error(t, p.Exec())
+	assert.Len(t, requests(), 2)
+
+	var start, end webhookEvent
+	assert.NoError(t, json.Unmarshal(requests()[0].body, &start))
+	assert.NoError(t, json.Unmarshal(requests()[1].body, &end))
+	assert.Equal(t, webhookStart, start.Event)
+	assert.Nil(t, start.Report)
+	assert.Equal(t, webhookFailure, end.Event)
+	assert.NotEmpty(t, end.Error)
+	assert.Equal(t, statusFailure, end.Report.Status)
+	assert.Equal(t, 1, end.Report.Files)
+	assert.Equal(t, statusFailure, end.Report.Hosts[0].Status)
+}